@@ -0,0 +1,88 @@
+package main
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/Finatext/measure-sfn/measure"
+)
+
+// noAlias is the Alias of by-alias.csv for executions started without one,
+// e.g. through the unqualified state machine ARN.
+const noAlias = "(none)"
+
+// createByAliasCsvFile writes the aggregate columns per state machine and
+// alias to by-alias.csv for --by-alias, so that e.g. a canary alias can be
+// compared with the stable one. Versions lists the versions each alias
+// routed the executions to, separated by semicolons.
+func createByAliasCsvFile(aggregated measure.AggregatedRecordMap) error {
+	w, err := createOutput(outputPath("by-alias.csv"))
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+	writer, err := newCsvWriter(w)
+	if err != nil {
+		return err
+	}
+
+	columns := aggregateColumns()
+	header := []string{"Name", "Alias", "Versions"}
+	for _, column := range columns {
+		header = append(header, column.Header)
+	}
+	rows := [][]string{header}
+
+	for _, name := range aggregateNames(aggregated) {
+		byAlias := make(map[string]measure.SfnRecords)
+		for _, record := range aggregated[name] {
+			alias := record.Alias
+			if alias == "" {
+				alias = noAlias
+			}
+			byAlias[alias] = append(byAlias[alias], record)
+		}
+
+		aliases := make([]string, 0, len(byAlias))
+		for alias := range byAlias {
+			aliases = append(aliases, alias)
+		}
+		// The executions without an alias come last.
+		slices.SortFunc(aliases, func(a, b string) int {
+			if (a == noAlias) != (b == noAlias) {
+				if a == noAlias {
+					return 1
+				}
+				return -1
+			}
+			return strings.Compare(a, b)
+		})
+
+		for _, alias := range aliases {
+			records := byAlias[alias]
+			row := []string{name, alias, aliasVersions(records)}
+			for _, column := range columns {
+				row = append(row, column.Value(records))
+			}
+			rows = append(rows, row)
+		}
+	}
+
+	if *noHeader {
+		rows = rows[1:]
+	}
+	return writer.WriteAll(rows)
+}
+
+// aliasVersions lists the distinct versions of the records in lexical order,
+// separated by semicolons.
+func aliasVersions(records measure.SfnRecords) string {
+	var versions []string
+	for _, record := range records {
+		if record.Version != "" {
+			versions = append(versions, record.Version)
+		}
+	}
+	slices.Sort(versions)
+	return strings.Join(slices.Compact(versions), ";")
+}
@@ -0,0 +1,30 @@
+package main
+
+import "github.com/Finatext/measure-sfn/measure"
+
+// createAnomaliesCsvFile writes the finished executions that had no start or
+// stop date, and therefore no record, to anomalies.csv.
+func createAnomaliesCsvFile(anomalies []measure.AnomalousExecution) error {
+	w, err := createOutput(outputPath("anomalies.csv"))
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+	writer, err := newCsvWriter(w)
+	if err != nil {
+		return err
+	}
+
+	if err := writer.Write([]string{"Name", "ExecutionArn", "Status", "Missing"}); err != nil {
+		return err
+	}
+
+	for _, a := range anomalies {
+		if err := writer.Write([]string{a.Name, a.ExecutionArn, a.Status, a.Missing}); err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
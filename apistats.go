@@ -0,0 +1,90 @@
+package main
+
+import (
+	"encoding/json"
+	"sync/atomic"
+	"time"
+
+	"github.com/Finatext/measure-sfn/measure"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/sfn"
+)
+
+// apiStats counts the SDK retries and the throttling errors of every client
+// instrumented with countRetries since the last take, i.e. over one cycle.
+var apiStats struct {
+	retries   atomic.Int64
+	throttled atomic.Int64
+}
+
+// countRetries adds the retries and throttling errors of every request made
+// by svc to apiStats. Throttling errors are counted whether or not they are
+// retried, so that the last attempt of a request is included.
+func countRetries(svc *sfn.SFN) {
+	svc.Handlers.AfterRetry.PushFront(func(r *request.Request) {
+		if request.IsErrorThrottle(r.Error) {
+			apiStats.throttled.Add(1)
+		}
+	})
+	svc.Handlers.Complete.PushBack(func(r *request.Request) {
+		apiStats.retries.Add(int64(r.RetryCount))
+	})
+}
+
+// takeAPIStats returns the counts of apiStats and resets them.
+func takeAPIStats() (retries, throttled int64) {
+	return apiStats.retries.Swap(0), apiStats.throttled.Swap(0)
+}
+
+// runSummary is the --summary-json encoding of the end-of-run summary.
+type runSummary struct {
+	RunID        string         `json:"run_id"`
+	Records      int            `json:"records"`
+	Statuses     map[string]int `json:"statuses"`
+	IdleMachines int            `json:"idle_machines"`
+	Anomalies    int            `json:"anomalies"`
+	Truncated    bool           `json:"truncated"`
+	// P95 is the overall p95 duration in seconds, and Machines the
+	// aggregate as in aggregate.json, so that the summary can serve as a
+	// --baseline-json.
+	P95            float64                   `json:"p95"`
+	Machines       map[string]aggregateStats `json:"machines"`
+	Retries        int64                     `json:"retries"`
+	Throttled      int64                     `json:"throttled"`
+	ElapsedSeconds float64                   `json:"elapsed_seconds"`
+}
+
+// createSummaryJSONFile writes the end-of-run summary to summary.json.
+func createSummaryJSONFile(result measure.Result, retries, throttled int64, elapsed time.Duration) error {
+	w, err := createOutput(outputPath("summary.json"))
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+
+	summary := runSummary{
+		RunID:          runID,
+		Records:        result.Records.Len(),
+		Statuses:       make(map[string]int),
+		IdleMachines:   len(result.Idle),
+		Anomalies:      len(result.Anomalies),
+		Truncated:      result.Truncated,
+		P95:            statsRecords(result.Records).Percentile(95).Seconds(),
+		Machines:       machineStats(statsRecords(result.Records).AggregateBy(groupKey)),
+		Retries:        retries,
+		Throttled:      throttled,
+		ElapsedSeconds: elapsed.Seconds(),
+	}
+	for status, records := range result.Records.GroupByStatus() {
+		summary.Statuses[status] = records.Len()
+	}
+
+	encoder := json.NewEncoder(w)
+	if !*compact {
+		encoder.SetIndent("", "  ")
+	}
+	if err := encoder.Encode(summary); err != nil {
+		return err
+	}
+	return w.Close()
+}
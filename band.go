@@ -0,0 +1,31 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// percentileBand is set by --percentile-band. Nil keeps every record.
+var percentileBand *band
+
+// band is a range of percentiles of each state machine's durations.
+type band struct {
+	low, high float64
+}
+
+// parsePercentileBand parses --percentile-band such as 95-100 or 0-50. An
+// empty value yields nil.
+func parsePercentileBand(value string) (*band, error) {
+	if value == "" {
+		return nil, nil
+	}
+
+	from, to, ok := strings.Cut(value, "-")
+	low, err1 := strconv.ParseFloat(strings.TrimSpace(from), 64)
+	high, err2 := strconv.ParseFloat(strings.TrimSpace(to), 64)
+	if !ok || err1 != nil || err2 != nil || low < 0 || high > 100 || low >= high {
+		return nil, fmt.Errorf("invalid --percentile-band %q: want two percentiles from 0 to 100 such as 95-100", value)
+	}
+	return &band{low: low, high: high}, nil
+}
@@ -0,0 +1,215 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/Finatext/measure-sfn/measure"
+)
+
+// baselineRun is set by --baseline-json.
+var baselineRun *baseline
+
+// baseline is a previous run read by --baseline-json: a summary.json of
+// --summary-json, or an aggregate.json, which lacks the overall p95.
+type baseline struct {
+	p95      *float64
+	machines map[string]aggregateStats
+}
+
+func readBaseline(path string) (baseline, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return baseline{}, err
+	}
+
+	// aggregate.json is keyed by state machine name, so a summary is told
+	// apart by its records and machines keys together.
+	var keys map[string]json.RawMessage
+	if err := json.Unmarshal(data, &keys); err != nil {
+		return baseline{}, fmt.Errorf("%s: %w", path, err)
+	}
+	_, records := keys["records"]
+	_, machines := keys["machines"]
+	if records && machines {
+		var summary struct {
+			P95      *float64                  `json:"p95"`
+			Machines map[string]aggregateStats `json:"machines"`
+		}
+		if err := json.Unmarshal(data, &summary); err != nil {
+			return baseline{}, fmt.Errorf("%s: %w", path, err)
+		}
+		return baseline{p95: summary.P95, machines: summary.Machines}, nil
+	}
+
+	var b baseline
+	if err := json.Unmarshal(data, &b.machines); err != nil {
+		return baseline{}, fmt.Errorf("%s: not a summary.json or aggregate.json: %w", path, err)
+	}
+	return b, nil
+}
+
+// machineDiff is the change of a state machine against the baseline, with
+// durations in seconds. AvgChange and P95Change are the relative changes,
+// e.g. 0.25 for 25% slower; they are omitted when the baseline value is 0.
+type machineDiff struct {
+	CountDelta int      `json:"count_delta"`
+	AvgDelta   float64  `json:"avg_delta"`
+	AvgChange  *float64 `json:"avg_change,omitempty"`
+	P95Delta   float64  `json:"p95_delta"`
+	P95Change  *float64 `json:"p95_change,omitempty"`
+	Regression bool     `json:"regression"`
+
+	before, after aggregateStats
+}
+
+// regressionValue returns the --regression-metric of s in seconds: the
+// average unless p95 is chosen.
+func regressionValue(s aggregateStats) float64 {
+	if *regressMetric == "p95" {
+		return s.P95
+	}
+	return s.Avg
+}
+
+// relativeChange returns the change from before to after relative to before,
+// or nil when before is 0.
+func relativeChange(before, after float64) *float64 {
+	if before <= 0 {
+		return nil
+	}
+	change := (after - before) / before
+	return &change
+}
+
+// baselineDiff is the diff.json encoding of the run against --baseline-json.
+// P95Delta is the change of the overall p95, omitted for an aggregate.json
+// baseline.
+type baselineDiff struct {
+	RunID       string                 `json:"run_id,omitempty"`
+	P95Delta    *float64               `json:"p95_delta,omitempty"`
+	Machines    map[string]machineDiff `json:"machines"`
+	Added       []string               `json:"added"`
+	Removed     []string               `json:"removed"`
+	Regressions []string               `json:"regressions"`
+}
+
+// diffBaseline compares the aggregate to the baseline. A state machine
+// regresses when its --regression-metric, the average by default, grew by
+// more than --regression-threshold percent of the baseline, and by more than
+// --regression-min-delta.
+func diffBaseline(b baseline, records measure.SfnRecords) baselineDiff {
+	current := machineStats(records.AggregateBy(groupKey))
+	diff := baselineDiff{
+		RunID:       runID,
+		Machines:    make(map[string]machineDiff),
+		Added:       []string{},
+		Removed:     []string{},
+		Regressions: []string{},
+	}
+	if b.p95 != nil {
+		delta := records.Percentile(95).Seconds() - *b.p95
+		diff.P95Delta = &delta
+	}
+
+	for name, after := range current {
+		before, ok := b.machines[name]
+		if !ok {
+			diff.Added = append(diff.Added, name)
+			continue
+		}
+		d := machineDiff{
+			CountDelta: after.Count - before.Count,
+			AvgDelta:   after.Avg - before.Avg,
+			AvgChange:  relativeChange(before.Avg, after.Avg),
+			P95Delta:   after.P95 - before.P95,
+			P95Change:  relativeChange(before.P95, after.P95),
+			before:     before,
+			after:      after,
+		}
+		if change := relativeChange(regressionValue(before), regressionValue(after)); change != nil {
+			delta := regressionValue(after) - regressionValue(before)
+			d.Regression = *change*100 > *regressPct && delta > regressDelta.Seconds()
+		}
+		if d.Regression {
+			diff.Regressions = append(diff.Regressions, name)
+		}
+		diff.Machines[name] = d
+	}
+	for name := range b.machines {
+		if _, ok := current[name]; !ok {
+			diff.Removed = append(diff.Removed, name)
+		}
+	}
+	slices.Sort(diff.Added)
+	slices.Sort(diff.Removed)
+	slices.Sort(diff.Regressions)
+	return diff
+}
+
+// createDiffJSONFile writes the diff against the baseline to diff.json.
+func createDiffJSONFile(diff baselineDiff) error {
+	w, err := createOutput(outputPath("diff.json"))
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+
+	encoder := json.NewEncoder(w)
+	if !*compact {
+		encoder.SetIndent("", "  ")
+	}
+	if err := encoder.Encode(diff); err != nil {
+		return err
+	}
+	return w.Close()
+}
+
+// createRegressionsCsvFile writes the regressed state machines alone to
+// regressions.csv for --regressions-only, with the --regression-metric (avg
+// by default, or p95) of the baseline and of this run, the delta and the
+// change in percent.
+func createRegressionsCsvFile(diff baselineDiff) error {
+	w, err := createOutput(outputPath("regressions.csv"))
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+	writer, err := newCsvWriter(w)
+	if err != nil {
+		return err
+	}
+
+	header := []string{"Name", "Metric", durationHeader("Old"), durationHeader("New"), durationHeader("Delta"), "ChangePct"}
+	if err := writer.Write(header); err != nil {
+		return err
+	}
+
+	seconds := func(s float64) string { return formatDuration(time.Duration(s * float64(time.Second))) }
+	for _, name := range diff.Regressions {
+		d := diff.Machines[name]
+		before, after := regressionValue(d.before), regressionValue(d.after)
+		row := []string{name, *regressMetric, seconds(before), seconds(after), seconds(after - before),
+			fmt.Sprintf("%.1f", *relativeChange(before, after)*100)}
+		if err := writer.Write(row); err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+// reportRegressions writes the regressed state machines to w and returns
+// their number.
+func reportRegressions(w io.Writer, diff baselineDiff) int {
+	if len(diff.Regressions) > 0 {
+		fmt.Fprintf(w, "%d state machines regressed against --baseline-json: %s\n", len(diff.Regressions), strings.Join(diff.Regressions, ", "))
+	}
+	return len(diff.Regressions)
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"strconv"
+
+	"github.com/Finatext/measure-sfn/measure"
+)
+
+func createStateBreakdownCsvFile(stats []measure.StateStats) error {
+	w, err := createOutput(outputPath("state-breakdown.csv"))
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+	writer, err := newCsvWriter(w)
+	if err != nil {
+		return err
+	}
+
+	if err := writer.Write([]string{"State", "Executions", "Entries", durationHeader("Total"), durationHeader("AvgPerExecution")}); err != nil {
+		return err
+	}
+
+	for _, s := range stats {
+		if err := writer.Write([]string{
+			s.Name,
+			strconv.Itoa(s.Executions),
+			strconv.Itoa(s.Entries),
+			formatDuration(s.Total),
+			formatDuration(s.AvgPerExecution()),
+		}); err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Finatext/measure-sfn/measure"
+)
+
+// business is set by --business-hours and --business-days. Nil leaves the
+// BusinessDuration column out.
+var business *businessHours
+
+// businessHours is a daily window of business days in a time zone. An end
+// before the start is an overnight window that ends the next day.
+type businessHours struct {
+	start, end time.Duration // since midnight
+	days       [7]bool       // indexed by time.Weekday
+	loc        *time.Location
+}
+
+var weekdayNames = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+// parseBusinessHours parses --business-hours such as 09:00-18:00 and
+// --business-days, a comma-separated list of days and day ranges such as
+// Mon-Fri or Mon,Wed,Fri. A range may wrap around the week, e.g. Sun-Thu or
+// Fri-Mon. An empty hours value yields nil.
+func parseBusinessHours(hours, days string, loc *time.Location) (*businessHours, error) {
+	if hours == "" {
+		return nil, nil
+	}
+
+	b := &businessHours{loc: loc}
+	from, to, ok := strings.Cut(hours, "-")
+	start, err1 := time.Parse("15:04", strings.TrimSpace(from))
+	end, err2 := time.Parse("15:04", strings.TrimSpace(to))
+	if !ok || err1 != nil || err2 != nil || start.Equal(end) {
+		return nil, fmt.Errorf("invalid --business-hours %q: want a start and an end such as 09:00-18:00", hours)
+	}
+	b.start = time.Duration(start.Hour())*time.Hour + time.Duration(start.Minute())*time.Minute
+	b.end = time.Duration(end.Hour())*time.Hour + time.Duration(end.Minute())*time.Minute
+
+	for _, part := range splitList(days) {
+		from, to, isRange := strings.Cut(part, "-")
+		first, ok1 := weekdayNames[strings.ToLower(strings.TrimSpace(from))]
+		last, ok2 := first, true
+		if isRange {
+			last, ok2 = weekdayNames[strings.ToLower(strings.TrimSpace(to))]
+		}
+		if !ok1 || !ok2 {
+			return nil, fmt.Errorf("invalid --business-days %q: want days such as Mon-Fri or Mon,Wed,Fri", days)
+		}
+		for d := first; ; d = (d + 1) % 7 {
+			b.days[d] = true
+			if d == last {
+				break
+			}
+		}
+	}
+	if b.days == [7]bool{} {
+		return nil, fmt.Errorf("invalid --business-days %q: want at least one day", days)
+	}
+	return b, nil
+}
+
+// within returns the part of the interval from start to stop that falls in
+// business hours. Each day's window is built from the wall clock in b.loc so
+// that daylight saving transitions lengthen or shorten it as they do the day.
+func (b *businessHours) within(start, stop time.Time) time.Duration {
+	if !stop.After(start) {
+		return 0
+	}
+
+	var total time.Duration
+	// Start a day early so that an overnight window opened the day before
+	// start is counted.
+	y, m, d := start.In(b.loc).AddDate(0, 0, -1).Date()
+	for day := time.Date(y, m, d, 0, 0, 0, 0, b.loc); day.Before(stop); day = day.AddDate(0, 0, 1) {
+		if !b.days[day.Weekday()] {
+			continue
+		}
+		open := b.clock(day, b.start)
+		closed := b.clock(day, b.end)
+		if b.end < b.start {
+			closed = b.clock(day.AddDate(0, 0, 1), b.end)
+		}
+		if overlap := minTime(closed, stop).Sub(maxTime(open, start)); overlap > 0 {
+			total += overlap
+		}
+	}
+	return total
+}
+
+// clock returns the wall clock time offset since midnight of day.
+func (b *businessHours) clock(day time.Time, offset time.Duration) time.Time {
+	return time.Date(day.Year(), day.Month(), day.Day(), int(offset/time.Hour), int(offset%time.Hour/time.Minute), 0, 0, b.loc)
+}
+
+func minTime(a, b time.Time) time.Time {
+	if a.Before(b) {
+		return a
+	}
+	return b
+}
+
+func maxTime(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
+
+// businessDuration formats the part of the execution in business hours.
+func businessDuration(r measure.SfnRecord) string {
+	return formatDuration(business.within(r.StartTime, r.StartTime.Add(r.Duration)))
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/Finatext/measure-sfn/measure"
+)
+
+const defaultTerminalWidth = 80
+
+// terminalWidth returns the width of the terminal as advertised by $COLUMNS,
+// falling back to defaultTerminalWidth.
+func terminalWidth() int {
+	if n, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil && n > 0 {
+		return n
+	}
+	return defaultTerminalWidth
+}
+
+// printChart writes a horizontal bar chart of the average duration per state
+// machine to w, slowest first, scaled to fit within width columns.
+func printChart(w io.Writer, aggregated measure.AggregatedRecordMap, width int) error {
+	type bar struct {
+		name  string
+		avg   float64
+		label string
+	}
+
+	bars := make([]bar, 0, len(aggregated))
+	nameWidth, labelWidth := 0, 0
+	longest := 0.0
+	for name, records := range aggregated {
+		avg := records.AvgDuration().Seconds()
+		b := bar{name: displayName(name), avg: avg, label: humanNumber(formatDuration(records.AvgDuration())) + outputUnit.symbol}
+		bars = append(bars, b)
+
+		nameWidth = max(nameWidth, utf8.RuneCountInString(b.name))
+		labelWidth = max(labelWidth, len(b.label))
+		longest = max(longest, avg)
+	}
+
+	sort.Slice(bars, func(i, j int) bool {
+		if bars[i].avg != bars[j].avg {
+			return bars[i].avg > bars[j].avg
+		}
+		return bars[i].name < bars[j].name
+	})
+
+	// name, bar and label are separated by a single space each.
+	barWidth := max(width-nameWidth-labelWidth-2, 1)
+
+	for _, b := range bars {
+		n := 0
+		if longest > 0 {
+			n = int(b.avg / longest * float64(barWidth))
+		}
+		padding := strings.Repeat(" ", nameWidth-utf8.RuneCountInString(b.name))
+		fill := strings.Repeat("#", n) + strings.Repeat(" ", barWidth-n)
+		if _, err := fmt.Fprintf(w, "%s%s %s %*s\n", b.name, padding, fill, labelWidth, b.label); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/Finatext/measure-sfn/measure"
+	"github.com/aws/aws-sdk-go/service/sfn"
+)
+
+// countOnlyIncompatible lists the flags that need execution records and
+// therefore cannot be combined with --count-only.
+var countOnlyIncompatible = map[string]bool{
+	"from-csv":          true,
+	"from-ndjson":       true,
+	"execution-arn":     true,
+	"stream":            true,
+	"summary-json":      true,
+	"baseline-json":     true,
+	"assert-window":     true,
+	"manifest":          true,
+	"post-command":      true,
+	"watch":             true,
+	"format":            true,
+	"out":               true,
+	"with-schema":       true,
+	"template":          true,
+	"slowest":           true,
+	"only-aggregate":    true,
+	"no-aggregate":      true,
+	"state-breakdown":   true,
+	"timeline":          true,
+	"with-map-items":    true,
+	"link-children":     true,
+	"failure-summary":   true,
+	"with-io-size":      true,
+	"contains-state":    true,
+	"name-style":        true,
+	"with-version":      true,
+	"version":           true,
+	"alias":             true,
+	"by-alias":          true,
+	"max-running":       true,
+	"max-p95":           true,
+	"sla":               true,
+	"fail-on-sla":       true,
+	"idle":              true,
+	"keep-negative":     true,
+	"tiers":             true,
+	"tier-fast":         true,
+	"tier-slow":         true,
+	"expected-interval": true,
+}
+
+// writeCounts counts the executions per state machine and status and writes
+// them to counts.csv, one column per execution status.
+func writeCounts(ctx context.Context, svc measure.Client, opts measure.Options) error {
+	counts, err := measure.Count(ctx, svc, opts)
+	if err != nil {
+		return err
+	}
+
+	w, err := createOutput(outputPath("counts.csv"))
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+	writer, err := newCsvWriter(w)
+	if err != nil {
+		return err
+	}
+
+	statuses := sfn.ExecutionStatus_Values()
+	if err := writer.Write(append([]string{"Name", "Count"}, statuses...)); err != nil {
+		return err
+	}
+
+	for _, count := range counts {
+		row := []string{count.Name, strconv.Itoa(count.Total())}
+		for _, status := range statuses {
+			row = append(row, strconv.Itoa(count.Statuses[status]))
+		}
+		if err := writer.Write(row); err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
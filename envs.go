@@ -0,0 +1,89 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// environment is a named profile and region pair from --profiles-config,
+// optionally assuming a role on top of the profile.
+type environment struct {
+	name    string
+	profile string
+	region  string
+	roleArn string
+}
+
+// readEnvironments reads one environment per line: the name, the profile, the
+// region and an optional role ARN, separated by whitespace. Blank lines and
+// lines starting with # are ignored as in readNameList.
+func readEnvironments(path string) (map[string]environment, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	envs := make(map[string]environment)
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) < 3 || len(fields) > 4 {
+			return nil, fmt.Errorf("%s:%d: want a name, a profile, a region and an optional role ARN", path, n)
+		}
+		if _, ok := envs[fields[0]]; ok {
+			return nil, fmt.Errorf("%s:%d: duplicate environment %s", path, n, fields[0])
+		}
+		env := environment{name: fields[0], profile: fields[1], region: fields[2]}
+		if len(fields) == 4 {
+			env.roleArn = fields[3]
+		}
+		envs[env.name] = env
+	}
+	return envs, scanner.Err()
+}
+
+// selectEnvironments returns the environments named by --env in order.
+func selectEnvironments(envs map[string]environment, names []string) ([]environment, error) {
+	selected := make([]environment, len(names))
+	for i, name := range names {
+		env, ok := envs[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown --env: %s", name)
+		}
+		selected[i] = env
+	}
+	return selected, nil
+}
+
+// createEnvTargets builds a client per environment. As in createTargets, the
+// environments are set up one after the other so that their MFA prompts never
+// compete for stdin. An environment without a role ARN falls back to
+// --role-arn.
+func createEnvTargets(ctx context.Context, c sessionConfig, envs []environment) ([]target, error) {
+	targets := make([]target, len(envs))
+	for i, env := range envs {
+		ec := c
+		ec.Profile, ec.Region = env.profile, env.region
+		if env.roleArn != "" {
+			ec.RoleArn = env.roleArn
+		}
+
+		t := target{env: env.name, profile: env.profile, region: env.region}
+		svc, err := createSfnSession(ctx, ec)
+		if err != nil {
+			return nil, &targetError{target: t, err: err}
+		}
+		t.client = svc
+		targets[i] = t
+	}
+	return targets, nil
+}
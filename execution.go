@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"os"
+	"time"
+
+	"github.com/Finatext/measure-sfn/measure"
+)
+
+// executionIncompatible lists the flags that select or filter executions
+// among many and therefore cannot be combined with --execution-arn.
+var executionIncompatible = map[string]bool{
+	"from-csv":          true,
+	"from-ndjson":       true,
+	"stream":            true,
+	"summary-json":      true,
+	"baseline-json":     true,
+	"assert-window":     true,
+	"manifest":          true,
+	"post-command":      true,
+	"watch":             true,
+	"state-machine-arn": true,
+	"state-breakdown":   true,
+	"timeline":          true,
+	"out":               true,
+	"only-aggregate":    true,
+	"no-aggregate":      true,
+	"slowest":           true,
+	"idle":              true,
+	"max-running":       true,
+	"max-p95":           true,
+	"sla":               true,
+	"fail-on-sla":       true,
+	"template":          true,
+	"failure-summary":   true,
+	"contains-state":    true,
+	"name-style":        true,
+	"changed-since":     true,
+}
+
+// measureExecution prints the record of a single execution to stdout in the
+// --format format, followed by the state it failed in when it did not
+// succeed.
+func measureExecution(ctx context.Context, svc measure.Client, loc *time.Location) error {
+	record, err := measure.DescribeRecord(ctx, svc, *executionArn, loc, now())
+	if err != nil {
+		return err
+	}
+
+	failedState := ""
+	if record.Failed() {
+		if failedState, err = measure.FailedState(ctx, svc, record.ExecutionArn); err != nil {
+			return err
+		}
+	}
+
+	columns := append(recordColumns(), recordColumn{"FailedState", columnString, func(measure.SfnRecord) string { return failedState }, "State the execution failed in"})
+	records := measure.SfnRecords{record}
+	if tiersEnabled() {
+		prepareTiers(records)
+	}
+	return createRecordsFile(os.Stdout.Name(), outputFormats[0], records, columns)
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"sort"
+	"strconv"
+
+	"github.com/Finatext/measure-sfn/measure"
+)
+
+// createFailureSummaryCsvFile groups the failed records by state machine and
+// failure reason and writes the count and average duration of every group to
+// failure-summary.csv, most frequent reason first within a state machine.
+func createFailureSummaryCsvFile(records measure.SfnRecords) error {
+	type reason struct {
+		name, error, cause string
+	}
+	groups := make(map[reason]measure.SfnRecords)
+	for _, record := range records {
+		if record.Failed() {
+			key := reason{record.Name, record.Error, record.Cause}
+			groups[key] = append(groups[key], record)
+		}
+	}
+
+	reasons := make([]reason, 0, len(groups))
+	for key := range groups {
+		reasons = append(reasons, key)
+	}
+	sort.Slice(reasons, func(i, j int) bool {
+		a, b := reasons[i], reasons[j]
+		if a.name != b.name {
+			return a.name < b.name
+		}
+		if len(groups[a]) != len(groups[b]) {
+			return len(groups[a]) > len(groups[b])
+		}
+		if a.error != b.error {
+			return a.error < b.error
+		}
+		return a.cause < b.cause
+	})
+
+	w, err := createOutput(outputPath("failure-summary.csv"))
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+	writer, err := newCsvWriter(w)
+	if err != nil {
+		return err
+	}
+
+	if err := writer.Write([]string{"Name", "Error", "Cause", "Count", durationHeader("AvgDuration")}); err != nil {
+		return err
+	}
+
+	for _, key := range reasons {
+		group := groups[key]
+		if err := writer.Write([]string{key.name, key.error, key.cause, strconv.Itoa(group.Len()), formatDuration(group.AvgDuration())}); err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
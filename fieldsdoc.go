@@ -0,0 +1,35 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+)
+
+// allColumns is set by --fields-doc so that recordColumns and aggregateColumns
+// return the columns of every flag.
+var allColumns bool
+
+func columnEnabled(enabled bool) bool {
+	return allColumns || enabled
+}
+
+// printFieldsDoc writes the name, type, unit and description of every column
+// of the records file and aggregate.csv to w as a table.
+func printFieldsDoc(w io.Writer) error {
+	allColumns = true
+	defer func() { allColumns = false }()
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "FILE\tFIELD\tTYPE\tUNIT\tDESCRIPTION")
+	fmt.Fprintf(tw, "records\trun_id\t%s\t\tRun ID of every JSON record (--run-id); CSV files carry it as a # run-id: comment line\n", columnString)
+	for _, column := range recordColumns() {
+		fmt.Fprintf(tw, "records\t%s\t%s\t%s\t%s\n", column.Header, column.Type, columnUnit(column.Header, column.Type), column.Doc)
+	}
+	fmt.Fprintf(tw, "aggregate\tName\t%s\t\tState machine name, or the --group-by-expr or --group-by-execution-name key\n", columnString)
+	for _, column := range aggregateColumns() {
+		fmt.Fprintf(tw, "aggregate\t%s\t%s\t%s\t%s\n", column.Header, column.Type, columnUnit(column.Header, column.Type), column.Doc)
+	}
+	fmt.Fprintf(tw, "aggregate\trun_id\t%s\t\tRun ID of every state machine of aggregate.json and of diff.json (--run-id)\n", columnString)
+	return tw.Flush()
+}
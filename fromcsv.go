@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/Finatext/measure-sfn/measure"
+)
+
+// fromCSVIncompatible lists the flags that need more than the records file
+// holds and therefore cannot be combined with --from-csv or --from-ndjson.
+var fromCSVIncompatible = map[string]bool{
+	"stream":            true,
+	"watch":             true,
+	"state-machine-arn": true,
+	"state-breakdown":   true,
+	"group-by-tag":      true,
+	"with-map-items":    true,
+	"link-children":     true,
+	"failure-summary":   true,
+	"max-running":       true,
+	"idle":              true,
+	"with-io-size":      true,
+	"env":               true,
+	"contains-state":    true,
+	"name-style":        true,
+	"changed-since":     true,
+	"with-version":      true,
+	"version":           true,
+	"alias":             true,
+	"by-alias":          true,
+	"org":               true,
+	"assert-window":     true,
+}
+
+// fromFileFlag returns the flag reading the records back from a file instead
+// of calling AWS, or "" when neither is set.
+func fromFileFlag() string {
+	switch {
+	case *fromCSV != "":
+		return "--from-csv"
+	case *fromNDJSON != "":
+		return "--from-ndjson"
+	}
+	return ""
+}
+
+func readRecordsFile(path string) (measure.SfnRecords, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	records, err := measure.ReadCSV(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return records, nil
+}
+
+// readNDJSONFile reads the records of an sfn.ndjson file, warning about each
+// malformed line it skips and about their count.
+func readNDJSONFile(path string) (measure.SfnRecords, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	records, skipped, err := measure.ReadNDJSON(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	for _, err := range skipped {
+		warnf("%s: skipping %v", path, err)
+	}
+	if len(skipped) > 0 {
+		warnf("%s: skipped %d malformed lines of %d", path, len(skipped), len(skipped)+records.Len())
+	}
+	return records, nil
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"text/template"
+
+	"github.com/Finatext/measure-sfn/measure"
+)
+
+// groupExpr is set by --group-by-expr and derives the key the aggregate
+// outputs group by from each record. Nil groups by the name as normalized by
+// --name-map.
+var groupExpr *template.Template
+
+// parseGroupExpr compiles a --group-by-expr template evaluated against each
+// measure.SfnRecord. Besides the record fields it can call:
+//
+//	tokens s sep n  the first n sep-separated tokens of s, joined by sep
+//	match re s      the first submatch of re in s, or the whole match
+//
+// e.g. {{tokens .Name "-" 2}} or {{match "^(.*)-(dev|prd)$" .Name}}. An
+// empty value yields a nil template.
+func parseGroupExpr(text string) (*template.Template, error) {
+	if text == "" {
+		return nil, nil
+	}
+
+	// The patterns are compiled once rather than for every record.
+	patterns := make(map[string]*regexp.Regexp)
+	tmpl, err := template.New("group").Funcs(template.FuncMap{
+		"tokens": func(s, sep string, n int) string {
+			if n <= 0 {
+				return ""
+			}
+			parts := strings.SplitN(s, sep, n+1)
+			return strings.Join(parts[:min(n, len(parts))], sep)
+		},
+		"match": func(expr, s string) (string, error) {
+			re, ok := patterns[expr]
+			if !ok {
+				var err error
+				if re, err = regexp.Compile(expr); err != nil {
+					return "", err
+				}
+				patterns[expr] = re
+			}
+			m := re.FindStringSubmatch(s)
+			switch {
+			case m == nil:
+				return "", nil
+			case len(m) > 1:
+				return m[1], nil
+			}
+			return m[0], nil
+		},
+	}).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid --group-by-expr: %w", err)
+	}
+	// Execute once so that unknown fields and invalid patterns fail up front
+	// rather than silently falling back for every record.
+	if _, err := executeGroupExpr(tmpl, measure.SfnRecord{}); err != nil {
+		return nil, fmt.Errorf("invalid --group-by-expr: %w", err)
+	}
+	return tmpl, nil
+}
+
+func executeGroupExpr(tmpl *template.Template, record measure.SfnRecord) (string, error) {
+	var b strings.Builder
+	if err := tmpl.Execute(&b, record); err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(b.String()), nil
+}
+
+// execNameGroup is set by --group-by-execution-name and groups the records by
+// a capture of their execution name instead, e.g. a pipeline run ID, across
+// state machines.
+var execNameGroup *regexp.Regexp
+
+// unmatchedGroup is the key of the records whose execution name does not
+// match --group-by-execution-name.
+const unmatchedGroup = "(unmatched)"
+
+// parseExecNameGroup compiles --group-by-execution-name, which must have a
+// capturing group. An empty value yields nil.
+func parseExecNameGroup(expr string) (*regexp.Regexp, error) {
+	if expr == "" {
+		return nil, nil
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid --group-by-execution-name: %w", err)
+	}
+	if re.NumSubexp() == 0 {
+		return nil, fmt.Errorf("invalid --group-by-execution-name %q: want a capturing group such as run-(\\w+)", expr)
+	}
+	return re, nil
+}
+
+// groupKey returns the key the aggregate outputs group record by: the first
+// capture of --group-by-execution-name in the execution name, or the
+// --group-by-expr value, falling back to the state machine ARN with
+// --with-arn, so that state machines of the same name in different regions or
+// accounts stay apart, and to the normalized name otherwise. aggregate.csv
+// still names an ARN group by its normalized name; see groupName.
+func groupKey(record measure.SfnRecord) string {
+	if execNameGroup != nil {
+		if m := execNameGroup.FindStringSubmatch(record.ExecutionName); m != nil {
+			return m[1]
+		}
+		return unmatchedGroup
+	}
+	if groupExpr != nil {
+		if key, err := executeGroupExpr(groupExpr, record); err == nil && key != "" {
+			return key
+		}
+	}
+	if *withArn && record.StateMachineArn != "" {
+		return record.StateMachineArn
+	}
+	return normalizeName(record.Name)
+}
+
+// groupName returns the Name of a group of aggregate.csv keyed by key: the
+// --name-map normalized name of its state machine when key is the ARN of
+// --with-arn, which the StateMachineArn column then shows, and key otherwise.
+func groupName(key string, records measure.SfnRecords) string {
+	if len(records) > 0 && key == records[0].StateMachineArn {
+		return normalizeName(records[0].Name)
+	}
+	return key
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/Finatext/measure-sfn/measure"
+)
+
+// healthWeights weigh the signals of healthScore. Only their ratios matter.
+type healthWeights struct {
+	success, tail, outliers float64
+}
+
+// weights is set by --health-weights.
+var weights = healthWeights{success: 50, tail: 30, outliers: 20}
+
+func healthEnabled() bool {
+	return *healthFlag || *sortAggr == "health"
+}
+
+// parseHealthWeights parses "success,tail,outliers" weights such as 50,30,20.
+func parseHealthWeights(value string) (healthWeights, error) {
+	fields := strings.Split(value, ",")
+	if len(fields) != 3 {
+		return healthWeights{}, fmt.Errorf("invalid --health-weights %q: want three comma-separated weights for success,tail,outliers", value)
+	}
+	var parsed [3]float64
+	for i, field := range fields {
+		w, err := strconv.ParseFloat(strings.TrimSpace(field), 64)
+		if err != nil || w < 0 {
+			return healthWeights{}, fmt.Errorf("invalid --health-weights %q: weights must be non-negative numbers", value)
+		}
+		parsed[i] = w
+	}
+	w := healthWeights{success: parsed[0], tail: parsed[1], outliers: parsed[2]}
+	if w.success+w.tail+w.outliers == 0 {
+		return healthWeights{}, fmt.Errorf("invalid --health-weights %q: at least one weight must be positive", value)
+	}
+	return w, nil
+}
+
+// healthScore blends three signals, each between 0 and 1, into a score from
+// 0 to 100 as their weighted mean:
+//
+//   - success: the fraction of executions with a --success-statuses status
+//   - tail: 1 / TailRatio, so 1 when the p95 is at most the average and
+//     smaller the longer the tail
+//   - outliers: 1 minus the fraction of executions beyond the Tukey fence,
+//     see measure.SfnRecords.Outliers
+func healthScore(r measure.SfnRecords, w healthWeights) float64 {
+	if r.Len() == 0 {
+		return 0
+	}
+
+	tail := 1.0
+	if ratio := r.TailRatio(); ratio > 1 {
+		tail = 1 / ratio
+	}
+	outliers := 1 - float64(r.Outliers())/float64(r.Len())
+
+	sum := w.success*r.SuccessRate(successStatuses...) + w.tail*tail + w.outliers*outliers
+	return 100 * sum / (w.success + w.tail + w.outliers)
+}
+
+// aggregateNames returns the group names in the --sort-aggregate order: by
+// name, or by health score with the least healthy first and ties by name.
+func aggregateNames(aggregated measure.AggregatedRecordMap) []string {
+	names := aggregated.Names()
+	if *sortAggr == "health" {
+		scores := make(map[string]float64, len(names))
+		for _, name := range names {
+			scores[name] = healthScore(aggregated[name], weights)
+		}
+		sort.SliceStable(names, func(i, j int) bool { return scores[names[i]] < scores[names[j]] })
+	}
+	return names
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Finatext/measure-sfn/measure"
+)
+
+// parseBucketEdges parses a comma-separated list of Go durations into
+// strictly increasing, positive bucket edges.
+func parseBucketEdges(s string) ([]time.Duration, error) {
+	var edges []time.Duration
+	for _, field := range strings.Split(s, ",") {
+		edge, err := time.ParseDuration(strings.TrimSpace(field))
+		if err != nil {
+			return nil, fmt.Errorf("invalid histogram bucket %q: %w", field, err)
+		}
+		if edge <= 0 {
+			return nil, fmt.Errorf("histogram bucket must be positive: %s", edge)
+		}
+		if len(edges) > 0 && edge <= edges[len(edges)-1] {
+			return nil, fmt.Errorf("histogram buckets must be strictly increasing: %s after %s", edge, edges[len(edges)-1])
+		}
+		edges = append(edges, edge)
+	}
+	return edges, nil
+}
+
+func histogramLabels(edges []time.Duration) []string {
+	labels := make([]string, 0, len(edges)+1)
+	labels = append(labels, "<"+edges[0].String())
+	for i := 1; i < len(edges); i++ {
+		labels = append(labels, edges[i-1].String()+"-"+edges[i].String())
+	}
+	return append(labels, ">="+edges[len(edges)-1].String())
+}
+
+func createHistogramCsvFile(aggregated measure.AggregatedRecordMap, edges []time.Duration) error {
+	w, err := createOutput(outputPath("histogram.csv"))
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+	writer, err := newCsvWriter(w)
+	if err != nil {
+		return err
+	}
+
+	if err := writer.Write(append([]string{"Name"}, histogramLabels(edges)...)); err != nil {
+		return err
+	}
+
+	for _, name := range aggregated.Names() {
+		row := []string{name}
+		for _, count := range aggregated[name].Histogram(edges) {
+			row = append(row, strconv.Itoa(count))
+		}
+		if err := writer.Write(row); err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"html/template"
+	"os"
+	"os/exec"
+	"runtime"
+
+	"github.com/Finatext/measure-sfn/measure"
+)
+
+const htmlReportPath = "report.html"
+
+var htmlReportTemplate = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+{{with .RunID}}<meta name="run-id" content="{{.}}">
+{{end}}<title>measure-sfn report</title>
+<style>
+body { font-family: sans-serif; }
+table { border-collapse: collapse; }
+th, td { border: 1px solid #ccc; padding: 4px 8px; }
+td.num { text-align: right; }
+</style>
+</head>
+<body>
+<h1>Step Functions execution durations</h1>
+<table>
+<tr><th>Name</th>{{range .Headers}}<th>{{.}}</th>{{end}}</tr>
+{{range .Rows}}<tr><td>{{.Name}}</td>{{range .Values}}<td class="num">{{.}}</td>{{end}}</tr>
+{{end}}</table>
+{{with .RunID}}<p>Run ID: {{.}}</p>
+{{end}}</body>
+</html>
+`))
+
+// createHTMLReport writes the aggregate columns to report.html as a single
+// self-contained table.
+func createHTMLReport(aggregated measure.AggregatedRecordMap) error {
+	type row struct {
+		Name   string
+		Values []string
+	}
+	data := struct {
+		RunID   string
+		Headers []string
+		Rows    []row
+	}{RunID: runID}
+
+	columns := aggregateColumns()
+	for _, column := range columns {
+		data.Headers = append(data.Headers, column.Header)
+	}
+	for _, name := range aggregated.Names() {
+		r := row{Name: name}
+		for _, column := range columns {
+			r.Values = append(r.Values, humanNumber(column.Value(aggregated[name])))
+		}
+		data.Rows = append(data.Rows, r)
+	}
+
+	w, err := createOutput(outputPath(htmlReportPath))
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+
+	if err := htmlReportTemplate.Execute(w, data); err != nil {
+		return err
+	}
+	return w.Close()
+}
+
+var htmlRecordsTemplate = template.Must(template.New("records").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+{{with .RunID}}<meta name="run-id" content="{{.}}">
+{{end}}<title>measure-sfn records</title>
+<style>
+body { font-family: sans-serif; }
+table { border-collapse: collapse; }
+th, td { border: 1px solid #ccc; padding: 4px 8px; }
+td.num { text-align: right; }
+</style>
+</head>
+<body>
+<table>
+<tr>{{range .Headers}}<th>{{.}}</th>{{end}}</tr>
+{{range .Rows}}<tr>{{range .}}<td{{if .Numeric}} class="num"{{end}}>{{.Value}}</td>{{end}}</tr>
+{{end}}</table>
+</body>
+</html>
+`))
+
+// createHTMLFile writes the records to path as a self-contained HTML table.
+func createHTMLFile(path string, records measure.SfnRecords, columns []recordColumn) error {
+	type cell struct {
+		Value   string
+		Numeric bool
+	}
+	data := struct {
+		RunID   string
+		Headers []string
+		Rows    [][]cell
+	}{RunID: runID}
+
+	for _, column := range columns {
+		data.Headers = append(data.Headers, column.Header)
+	}
+	for _, record := range records {
+		row := make([]cell, len(columns))
+		for i, column := range columns {
+			row[i] = cell{displayValue(column, record), column.Type != columnString}
+		}
+		data.Rows = append(data.Rows, row)
+	}
+
+	w, err := createOutput(path)
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+
+	if err := htmlRecordsTemplate.Execute(w, data); err != nil {
+		return err
+	}
+	return w.Close()
+}
+
+// openInBrowser opens path with the platform's default handler. Unsupported
+// platforms and Unix sessions without a display only produce a warning.
+func openInBrowser(path string) {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("open", path)
+	case "windows":
+		cmd = exec.Command("cmd", "/c", "start", "", path)
+	case "linux", "freebsd", "openbsd", "netbsd":
+		if os.Getenv("DISPLAY") == "" && os.Getenv("WAYLAND_DISPLAY") == "" {
+			warnf("--open: no display available, not opening %s", path)
+			return
+		}
+		cmd = exec.Command("xdg-open", path)
+	default:
+		warnf("--open: unsupported platform %s, not opening %s", runtime.GOOS, path)
+		return
+	}
+
+	if err := cmd.Start(); err != nil {
+		warnf("--open: %v", err)
+		return
+	}
+	// The opener hands the file to the browser and exits; don't leave a zombie.
+	go cmd.Wait()
+}
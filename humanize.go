@@ -0,0 +1,55 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+)
+
+// thousandsSep is set by --thousands-sep. It only affects outputs meant for
+// people, i.e. Markdown, HTML and the terminal, never CSV or JSON.
+var thousandsSep bool
+
+// maxNameWidth is set by --name-width and bounds the state machine names shown
+// in the chart and Markdown tables. Zero shows names in full.
+var maxNameWidth int
+
+// displayName truncates name to maxNameWidth characters, ending it with an
+// ellipsis when it was cut.
+func displayName(name string) string {
+	runes := []rune(name)
+	if maxNameWidth <= 0 || len(runes) <= maxNameWidth {
+		return name
+	}
+	return string(runes[:maxNameWidth-1]) + "…"
+}
+
+// humanNumber groups the integer digits of a formatted number with commas,
+// e.g. 1234567.50 becomes 1,234,567.50, when thousandsSep is set. Anything
+// that is not a number is returned unchanged.
+func humanNumber(s string) string {
+	if !thousandsSep {
+		return s
+	}
+	if _, err := strconv.ParseFloat(s, 64); err != nil {
+		return s
+	}
+
+	sign := ""
+	if strings.HasPrefix(s, "-") {
+		sign, s = "-", s[1:]
+	}
+	integer, fraction, hasFraction := strings.Cut(s, ".")
+
+	var b strings.Builder
+	for i, digit := range integer {
+		if i > 0 && (len(integer)-i)%3 == 0 {
+			b.WriteByte(',')
+		}
+		b.WriteRune(digit)
+	}
+	if hasFraction {
+		b.WriteByte('.')
+		b.WriteString(fraction)
+	}
+	return sign + b.String()
+}
@@ -0,0 +1,28 @@
+package main
+
+import "github.com/Finatext/measure-sfn/measure"
+
+func createIdleCsvFile(idle []measure.IdleMachine) error {
+	w, err := createOutput(outputPath("idle.csv"))
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+	writer, err := newCsvWriter(w)
+	if err != nil {
+		return err
+	}
+
+	if err := writer.Write([]string{"Name", "StateMachineArn"}); err != nil {
+		return err
+	}
+
+	for _, machine := range idle {
+		if err := writer.Write([]string{machine.Name, machine.StateMachineArn}); err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
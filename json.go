@@ -0,0 +1,201 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+
+	"github.com/Finatext/measure-sfn/measure"
+	"github.com/aws/aws-sdk-go/service/sfn"
+)
+
+// jsonRecord encodes a record as an object with one member per column, in
+// column order, led by run_id when a run ID is set so that every line of
+// sfn.ndjson carries it.
+type jsonRecord struct {
+	record  measure.SfnRecord
+	columns []recordColumn
+}
+
+func (j jsonRecord) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	if runID != "" {
+		id, err := json.Marshal(runID)
+		if err != nil {
+			return nil, err
+		}
+		buf.WriteString(`"run_id":`)
+		buf.Write(id)
+	}
+	for i, column := range j.columns {
+		if i > 0 || runID != "" {
+			buf.WriteByte(',')
+		}
+		key, err := json.Marshal(column.Header)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(key)
+		buf.WriteByte(':')
+
+		var value any = column.Value(j.record)
+		if column.Type != columnString && (column.Type != columnDuration || durationIsNumber()) {
+			// An empty number, such as PctOfMedian of a zero median, is null.
+			if s := value.(string); s == "" {
+				value = nil
+			} else {
+				value = json.Number(s)
+			}
+		}
+		encoded, err := json.Marshal(value)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(encoded)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+type jsonField struct {
+	Name string   `json:"name"`
+	Type string   `json:"type"`
+	Unit string   `json:"unit,omitempty"`
+	Enum []string `json:"enum,omitempty"`
+}
+
+type jsonSchema struct {
+	Fields []jsonField `json:"fields"`
+}
+
+// byteColumns are the integer columns holding sizes in bytes. Other integer
+// columns, such as ChildExecutions, are counts without a unit.
+var byteColumns = map[string]bool{
+	"InputBytes":  true,
+	"OutputBytes": true,
+}
+
+// columnUnit returns the unit of a column: the --unit symbol for numeric
+// durations, bytes for the byteColumns, and none for anything else.
+func columnUnit(header string, t columnType) string {
+	switch {
+	case t == columnDuration && durationIsNumber():
+		return outputUnit.symbol
+	case t == columnInteger && byteColumns[header]:
+		return "bytes"
+	}
+	return ""
+}
+
+// recordSchema describes the JSON type of every column, after the run_id of
+// every record when a run ID is set. Durations are numbers in the --unit unit
+// and Status is one of the SFN execution statuses.
+func recordSchema(columns []recordColumn) jsonSchema {
+	var schema jsonSchema
+	if runID != "" {
+		schema.Fields = append(schema.Fields, jsonField{Name: "run_id", Type: string(columnString)})
+	}
+	for _, column := range columns {
+		field := jsonField{Name: column.Header, Type: string(column.Type), Unit: columnUnit(column.Header, column.Type)}
+		if column.Type == columnDuration {
+			field.Type = "number"
+			if !durationIsNumber() {
+				field.Type = "string"
+			}
+		}
+		if column.Header == "Status" {
+			field.Enum = sfn.ExecutionStatus_Values()
+		}
+		schema.Fields = append(schema.Fields, field)
+	}
+	return schema
+}
+
+// createJSONFile writes the records to path as an array, or wrapped
+// together with their schema when withSchema is set. Each record carries the
+// run ID when one is set. compact writes a single line instead of indenting.
+func createJSONFile(path string, records measure.SfnRecords, columns []recordColumn, withSchema, compact bool) error {
+	w, err := createOutput(path)
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+
+	rows := make([]jsonRecord, len(records))
+	for i, record := range records {
+		rows[i] = jsonRecord{record: record, columns: columns}
+	}
+
+	var v any = rows
+	if withSchema {
+		v = struct {
+			RunID   string       `json:"run_id,omitempty"`
+			Schema  jsonSchema   `json:"schema"`
+			Records []jsonRecord `json:"records"`
+		}{runID, recordSchema(columns), rows}
+	}
+
+	encoder := json.NewEncoder(w)
+	if !compact {
+		encoder.SetIndent("", "  ")
+	}
+	if err := encoder.Encode(v); err != nil {
+		return err
+	}
+	return w.Close()
+}
+
+// aggregateStats is the JSON encoding of a state machine's aggregate, with
+// durations in seconds regardless of --unit.
+type aggregateStats struct {
+	// RunID is only set in aggregate.json, whose top level is keyed by name.
+	RunID string  `json:"run_id,omitempty"`
+	Max   float64 `json:"max"`
+	Min   float64 `json:"min"`
+	Avg   float64 `json:"avg"`
+	Count int     `json:"count"`
+	P95   float64 `json:"p95"`
+}
+
+// machineStats returns the aggregateStats of every group of aggregated.
+func machineStats(aggregated measure.AggregatedRecordMap) map[string]aggregateStats {
+	stats := make(map[string]aggregateStats, len(aggregated))
+	for name, records := range aggregated {
+		s := records.Stats()
+		stats[name] = aggregateStats{
+			Max:   s.Max.Seconds(),
+			Min:   s.Min.Seconds(),
+			Avg:   s.Avg.Seconds(),
+			Count: s.Count,
+			P95:   s.P95.Seconds(),
+		}
+	}
+	return stats
+}
+
+// createAggregateJSONFile writes the aggregate to aggregate.json as an object
+// keyed by state machine name, for lookups without parsing CSV rows. The run
+// ID, having no key of its own there, is repeated in every state machine.
+func createAggregateJSONFile(aggregated measure.AggregatedRecordMap) error {
+	w, err := createOutput(outputPath("aggregate.json"))
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+
+	stats := machineStats(aggregated)
+	for name, s := range stats {
+		s.RunID = runID
+		stats[name] = s
+	}
+
+	encoder := json.NewEncoder(w)
+	if !*compact {
+		encoder.SetIndent("", "  ")
+	}
+	// Maps are encoded with sorted keys, so the output is stable.
+	if err := encoder.Encode(stats); err != nil {
+		return err
+	}
+	return w.Close()
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+
+	"github.com/Finatext/measure-sfn/measure"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+)
+
+// jsonError is the --json-errors encoding of a failed run. Profile and Region
+// are those of the failing target, or of the flags for a single target.
+type jsonError struct {
+	Code         string `json:"code"`
+	Message      string `json:"message"`
+	Env          string `json:"env,omitempty"`
+	Profile      string `json:"profile,omitempty"`
+	Region       string `json:"region,omitempty"`
+	Account      string `json:"account,omitempty"`
+	StateMachine string `json:"state_machine,omitempty"`
+}
+
+// errorCode classifies err: the AWS error code for API failures such as
+// AccessDeniedException or ThrottlingException, gate_failed, canceled or
+// deadline_exceeded, and error for anything else.
+func errorCode(err error) string {
+	var aerr awserr.Error
+	switch {
+	case errors.Is(err, errGateFailed):
+		return "gate_failed"
+	case errors.Is(err, context.DeadlineExceeded):
+		return "deadline_exceeded"
+	case errors.Is(err, context.Canceled):
+		return "canceled"
+	case errors.As(err, &aerr):
+		return aerr.Code()
+	}
+	return "error"
+}
+
+// writeJSONError writes err to w as a single-line jsonError.
+func writeJSONError(w io.Writer, err error) error {
+	e := jsonError{Code: errorCode(err), Message: err.Error(), Profile: *profile, Region: *region}
+
+	var terr *targetError
+	if errors.As(err, &terr) {
+		e.Env, e.Profile, e.Account = terr.target.env, terr.target.profile, terr.target.account
+		if terr.target.region != "" {
+			e.Region = terr.target.region
+		}
+	}
+	var merr *measure.MachineError
+	if errors.As(err, &merr) {
+		e.StateMachine = merr.StateMachineArn
+	}
+	return json.NewEncoder(w).Encode(e)
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/service/sfn"
+)
+
+// parseListInput decodes --list-input-json into a ListExecutions template. The
+// members follow the API reference, e.g. statusFilter or redriveFilter. The
+// state machine ARN, map run ARN and page token are managed by the tool and
+// rejected.
+func parseListInput(value string) (*sfn.ListExecutionsInput, error) {
+	if value == "" {
+		return nil, nil
+	}
+
+	var input sfn.ListExecutionsInput
+	decoder := json.NewDecoder(bytes.NewReader([]byte(value)))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&input); err != nil {
+		return nil, fmt.Errorf("--list-input-json: %w", err)
+	}
+
+	if input.StateMachineArn != nil || input.MapRunArn != nil || input.NextToken != nil {
+		return nil, errors.New("--list-input-json cannot set stateMachineArn, mapRunArn or nextToken")
+	}
+	return &input, nil
+}
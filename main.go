@@ -1,137 +1,1321 @@
 package main
 
 import (
-	"encoding/csv"
+	"context"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"os"
+	"os/signal"
+	"slices"
+	"strconv"
 	"strings"
+	"syscall"
+	"text/template"
 	"time"
 
-	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/aws/credentials/stscreds"
-	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/Finatext/measure-sfn/measure"
 	"github.com/aws/aws-sdk-go/service/sfn"
 )
 
 var (
-	profile = flag.String("profile", "", "AWS profile")
+	profile       = flag.String("profile", "", "AWS profile, or comma-separated profiles scanned together (defaults to $AWS_PROFILE)")
+	profileConc   = flag.Int("profile-concurrency", 1, "Number of profiles and regions collected at the same time")
+	region        = flag.String("region", "", "AWS region, or comma-separated regions whose roll-up goes to by-region.csv (defaults to $AWS_REGION, then the profile's region)")
+	profilesCfg   = flag.String("profiles-config", "", "File of environments, one per line: a name, a profile, a region and an optional role ARN")
+	envFlag       = flag.String("env", "", "Comma-separated environments of --profiles-config to measure instead of --profile and --region, tagging the records with an Env column")
+	org           = flag.Bool("org", false, "Measure every active account of the AWS Organization listed with --profile, assuming --org-role in each")
+	orgRole       = flag.String("org-role", "OrganizationAccountAccessRole", "Name of the role assumed in each account with --org")
+	allRegions    = flag.Bool("all-regions", false, "Scan every region enabled for the account, discovered with EC2 DescribeRegions called in --region")
+	partition     = flag.String("partition", "", "AWS partition: aws, aws-us-gov or aws-cn (inferred from the region when unset)")
+	roleArn       = flag.String("role-arn", "", "IAM role ARN to assume on top of the profile credentials")
+	insecure      = flag.Bool("insecure-skip-verify", false, "Skip TLS certificate verification of the AWS API calls, e.g. behind a proxy with a self-signed certificate; unsafe")
+	roleDuration  = flag.Duration("assume-role-duration", defaultAssumeRoleDuration, "Lifetime of assumed-role sessions, of --role-arn and role_arn profiles; the role's maximum session duration must allow it")
+	stsRegion     = flag.String("sts-region", "", "Resolve assumed-role credentials through the regional STS endpoint of this region instead of the SDK default")
+	mfaSerial     = flag.String("mfa-serial", "", "MFA device serial number used when assuming --role-arn")
+	configFile    = flag.String("config-file", "", "Path of the shared AWS config file instead of $AWS_CONFIG_FILE or ~/.aws/config")
+	credsFile     = flag.String("credentials-file", "", "Path of the shared AWS credentials file instead of $AWS_SHARED_CREDENTIALS_FILE or ~/.aws/credentials")
+	noSharedCfg   = flag.Bool("no-shared-config", false, "Do not read ~/.aws/config, e.g. in containers with only environment credentials; requires AWS_REGION or --region")
+	nonInter      = flag.Bool("non-interactive", false, "Fail instead of prompting on stdin when an MFA token is required")
+	mfaToken      = flag.String("mfa-token", "", "MFA token to answer the first MFA prompt with instead of stdin, for scripted single-shot runs; it is visible in the shell history")
+	chart         = flag.Bool("chart", false, "Print an ASCII bar chart of the average duration per state machine")
+	sparkLine     = flag.Bool("sparkline", false, "Print the executions per hour of day of each state machine as a sparkline, in --timezone")
+	maxP95        = flag.Duration("max-p95", 0, "Exit non-zero when the overall p95 duration exceeds this value")
+	containsState = flag.String("contains-state", "", "Keep only the executions that entered the named state, reading the history of every execution")
+	withArn       = flag.Bool("with-arn", false, "Add a StateMachineArn column to the records and the aggregate, and group the aggregate by ARN rather than --name-map name, to tell apart state machines of the same name in different regions or accounts")
+	withIO        = flag.Bool("with-io-size", false, "Call DescribeExecution per execution to record input/output sizes")
+	timeFormat    = flag.String("time-format", time.DateOnly, "Go reference-time layout of the StartDate column, e.g. \"2006-01-02 15:04:05\" or 2006-01-02T15:04:05Z07:00")
+	timezone      = flag.String("timezone", "UTC", "IANA time zone used for dates in the output")
+	busHours      = flag.String("business-hours", "", "Add a BusinessDuration column to the records: the part of each execution within these daily hours in --timezone, e.g. 09:00-18:00")
+	busDays       = flag.String("business-days", "Mon-Fri", "Days --business-hours applies to, e.g. Mon-Fri or Mon,Wed,Fri")
+	groupBy       = flag.String("group-by", "", "Additionally roll records up by period: month")
+	groupFmt      = flag.String("group-format", "long", "Layout of the rolled up file: long or wide")
+	lineEnding    = flag.String("line-ending", "lf", "Line ending of the CSV and TSV outputs: lf or crlf")
+	rateLimit     = flag.Float64("rate-limit", 0, "Maximum SFN API calls per second and region, including retries; 0 disables limiting")
+	ghSummary     = flag.Bool("github-summary", false, "Write a Markdown table to $GITHUB_STEP_SUMMARY, or stdout when unset")
+	histogram     = flag.Bool("histogram", false, "Write duration histograms per state machine to histogram.csv")
+	histEdges     = flag.String("histogram-buckets", "1s,10s,1m,5m,15m,1h", "Comma-separated, strictly increasing histogram bucket edges")
+	top           = flag.Int("top", 0, "Keep only the N slowest state machines in the aggregate outputs; 0 keeps all")
+	topBy         = flag.String("top-by", "avg", "Metric ranking the state machines for --top: max, avg or p95")
+	unit          = flag.String("unit", "s", "Unit of the duration columns: s, ms, min or h")
+	durFormat     = flag.String("duration-format", "number", "Format of the duration columns: number, in --unit; iso8601, e.g. PT1M33.5S; or human, e.g. 1m33.5s")
+	maxRunning    = flag.Duration("max-running", 0, "Write running executions older than this to stuck.csv")
+	slaFlag       = flag.String("sla", "", "Per state machine SLA such as p99:30s, adding an SLAMet column to the aggregate and listing the breaches")
+	failSLA       = flag.Bool("fail-on-sla", false, "Exit non-zero when a state machine breaches --sla")
+	failStuck     = flag.Bool("fail-on-stuck", false, "Exit non-zero when --max-running finds stuck executions")
+	format        = flag.String("format", "auto", "Comma-separated formats of the records file sfn.<format>: csv, tsv, json, ndjson, md, html or parquet; auto picks it from the --out extension, defaulting to csv")
+	out           = flag.String("out", "", "Path of the records file instead of sfn.<format>, with the extension replaced per format when there are several; FIFOs and /dev/stdout work too")
+	noHeader      = flag.Bool("no-header", false, "Leave the header row out of the records CSV/TSV, the per-machine files, aggregate.csv and by-tag.csv, e.g. to concatenate daily files")
+	baselineJSON  = flag.String("baseline-json", "", "Compare the aggregate to a previous summary.json or aggregate.json and write the per-state-machine deltas to diff.json")
+	regressPct    = flag.Float64("regression-threshold", 10, "Percent increase of --regression-metric over --baseline-json above which a state machine regressed")
+	regressDelta  = flag.Duration("regression-min-delta", 0, "Absolute --regression-metric increase over --baseline-json a regression must also exceed")
+	regressMetric = flag.String("regression-metric", "avg", "Metric compared against --baseline-json for regressions: avg or p95")
+	failRegress   = flag.Bool("fail-on-regression", false, "Exit non-zero when a state machine regressed against --baseline-json")
+	regressOnly   = flag.Bool("regressions-only", false, "Write only the regressed state machines, with the old and new --regression-metric, to regressions.csv instead of diff.json, and exit non-zero when there are any")
+	summaryJSON   = flag.Bool("summary-json", false, "Write the end-of-run summary, with the record counts per status and the API retries and throttling errors, to summary.json")
+	compact       = flag.Bool("compact", false, "Write sfn.json, aggregate.json and summary.json on a single line instead of pretty-printing them")
+	manifest      = flag.Bool("manifest", false, "Write manifest.json listing the size and SHA-256 of every file the run wrote, with the run ID and the flags")
+	withSchema    = flag.Bool("with-schema", false, "Wrap JSON records as {\"schema\": ..., \"records\": [...]}")
+	splitStatus   = flag.Bool("split-by-status", false, "Also write the records of each observed status to sfn-<status>.csv, e.g. sfn-failed.csv")
+	perMachine    = flag.String("per-machine-dir", "", "Also write each state machine's records to <dir>/<name>.csv, with a short hash appended to names that would share a file")
+	fieldsDoc     = flag.Bool("fields-doc", false, "Print the name, type, unit and description of every column of the records file and aggregate.csv and exit")
+	whoami        = flag.Bool("whoami", false, "Print the account, ARN and user ID the profile resolves to with STS GetCallerIdentity and exit")
+	listProfs     = flag.Bool("list-profiles", false, "Print the profiles in the shared AWS config and credentials files and exit")
+	tmplText      = flag.String("template", "", "Go text/template executed per record, printing one line per record to stdout")
+	retryMode     = flag.String("retry-mode", defaultRetryMode(), "SDK retry mode: standard or adaptive (defaults to $AWS_RETRY_MODE)")
+	retryOn       = flag.String("retry-on", "", "Comma-separated AWS error codes to retry in addition to the SDK's throttling and transient errors")
+	maxAttempt    = flag.Int("max-attempts", defaultMaxAttempts(), "Maximum attempts per API call including the first (defaults to $AWS_MAX_ATTEMPTS)")
+	jsonErrors    = flag.Bool("json-errors", false, "On failure, write the error to stderr as a JSON object with a code, the message and the failing profile, region and state machine")
+	quiet         = flag.Bool("quiet", false, "Suppress the chart, warnings and the run summary")
+	filterBy      = flag.String("filter-by", "start", "Timestamp the lookback window applies to: start or stop")
+	assertWindow  = flag.Bool("assert-window", false, "Fail if any collected record falls outside the lookback window, as a self-check of the filtering")
+	withVersion   = flag.Bool("with-version", false, "Call DescribeExecution per execution to add the state machine version as a column")
+	versionFilter = flag.String("version", "", "Keep only executions of this state machine version number (implies DescribeExecution calls)")
+	aliasFilter   = flag.String("alias", "", "Keep only executions started through this alias name (implies DescribeExecution calls)")
+	relative      = flag.Bool("relative", false, "Add a PctOfMedian column to the records: the duration as a percentage of the state machine's median")
+	skipFirst     = flag.Int("skip-first", 0, "Leave the N earliest started executions of each state machine out of the aggregate stats and gates, e.g. cold starts after a deploy; the records file keeps them")
+	minNonZero    = flag.Bool("min-nonzero-duration", false, "Leave zero-duration executions out of the aggregate stats and gates; the records file keeps them")
+	keepNegative  = flag.Bool("keep-negative", false, "Keep executions whose stop date is before their start date instead of skipping them")
+	timeout       = flag.Duration("timeout", 0, "Overall deadline for the run, including credential resolution; 0 means none")
+	apiTimeout    = flag.Duration("api-timeout", 0, "Deadline for each attempt of an API call, after which it is retried; 0 means none")
+	minExecutions = flag.Int("min-executions", 0, "Leave state machines with fewer executions out of the aggregate outputs; the records file keeps them")
+	aggrJSON      = flag.Bool("aggregate-json", false, "Also write the aggregate to aggregate.json as an object keyed by state machine name, with durations in seconds")
+	failureSum    = flag.Bool("failure-summary", false, "Call DescribeExecution per failed execution and count the failures per state machine, error and cause in failure-summary.csv")
+	tailThreshold = flag.Float64("tail-threshold", 0, "List the state machines whose p95/avg TailRatio exceeds this value in tail-heavy.csv")
+	statusCounts  = flag.Bool("status-counts", false, "Write the number of executions per state machine and status to status-counts.csv")
+	statusTrend   = flag.Bool("status-trend", false, "Write the number of executions per state machine, ISO week and status to status-trend.csv")
+	statusSet     = flag.Bool("statuses", false, "Write the distinct statuses each state machine produced to statuses.csv")
+	weekdayWknd   = flag.Bool("weekday-weekend", false, "Compare weekday and weekend executions per state machine in weekday-weekend.csv")
+	namePrefix    = flag.String("execution-name-prefix", "", "Keep only executions whose name starts with this prefix")
+	onlyRunning   = flag.Bool("only-running", false, "Only list the executions running now into running.csv, the longest running first, without reading finished executions")
+	countOnly     = flag.Bool("count-only", false, "Only count the executions per state machine and status into counts.csv, without building records")
+	executionArn  = flag.String("execution-arn", "", "Describe only this execution and print its record, with the state it failed in, to stdout")
+	machineArn    = flag.String("state-machine-arn", "", "Measure only this state machine instead of the whole account")
+	includeFile   = flag.String("include-file", "", "File of state machine names, one per line, to measure exclusively")
+	excludeFile   = flag.String("exclude-file", "", "File of state machine names, one per line, to skip; wins over --include-file")
+	runIDFlag     = flag.String("run-id", "", "Tag every output file with this run ID, or a random UUID for \"auto\"; CSV files get a leading # comment line and JSON records a run_id field")
+	groupByExec   = flag.String("group-by-execution-name", "", "Regexp whose first capture of the execution name, e.g. a pipeline run ID, is the key the aggregate outputs group by; other executions go to (unmatched)")
+	groupByExpr   = flag.String("group-by-expr", "", "Template deriving the key the aggregate outputs group by from each record, e.g. {{tokens .Name \"-\" 2}}; an empty result falls back to the name")
+	changedFrom   = flag.String("changed-since", "", "Only measure the state machines created after this RFC 3339 time, date or duration ago such as 168h")
+	changedByVer  = flag.Bool("changed-by-version", false, "With --changed-since, also measure the state machines with a version published after it; calls ListStateMachineVersions per state machine")
+	nameStyle     = flag.String("name-style", measure.NameStyleName, "How the state machine name of each record is derived from its ARN: name, name-version (calls DescribeExecution per execution) or arn")
+	nameMapFile   = flag.String("name-map", "", "File of regexp and replacement pairs, one per line, normalizing the state machine names the aggregate outputs group by")
+	groupByTagKey = flag.String("group-by-tag", "", "Additionally aggregate the state machines by the value of this tag key into by-tag.csv")
+	byAlias       = flag.Bool("by-alias", false, "Call DescribeExecution per execution and aggregate each state machine per alias, with the versions it routed to, into by-alias.csv")
+	cacheDir      = flag.String("cache-dir", "", "Directory caching slowly changing API results such as tags between runs")
+	tagCacheTTL   = flag.Duration("tag-cache-ttl", 24*time.Hour, "Age after which cached tags in --cache-dir are refetched")
+	cacheGzip     = flag.Bool("cache-compress", true, "Gzip the files written to --cache-dir; compressed and plain files are both read")
+	successStat   = flag.String("success-statuses", sfn.ExecutionStatusSucceeded, "Comma-separated execution statuses counting as success in the success rates, e.g. SUCCEEDED,ABORTED; any other status is a failure")
+	excludeStat   = flag.String("exclude-status", "", "Comma-separated execution statuses to drop, e.g. ABORTED,TIMED_OUT")
+	excludeAbort  = flag.Bool("exclude-aborted", false, "Drop manually aborted executions; shorthand for --exclude-status ABORTED")
+	maxRecords    = flag.Int("max-records", 0, "Stop fetching once this many records have been collected across all state machines and write them with a warning; 0 means unlimited")
+	concurrency   = flag.Int("concurrency", 1, "Maximum SFN API calls in flight across state machines and DescribeExecution calls")
+	outDir        = flag.String("output-dir", "", "Directory all output files are written to, created if needed; relative output paths resolve against it")
+	stream        = flag.Bool("stream", false, "Write records to sfn.ndjson one state machine at a time without holding them all in memory; aggregate percentiles are approximate")
+	noAggregate   = flag.Bool("no-aggregate", false, "Only write the records file, skipping aggregate.csv and everything derived from it")
+	postCommand   = flag.String("post-command", "", "Shell command run after the outputs are written, with the records file as $1 and on stdin")
+	postRequired  = flag.Bool("post-required", false, "Fail the run when --post-command exits non-zero instead of warning")
+	transpose     = flag.Bool("transpose", false, "Write aggregate.csv and the other per-group aggregates with a row per metric and a column per group")
+	onlyAggr      = flag.Bool("only-aggregate", false, "Skip the records file and only write the aggregate outputs")
+	compactAggr   = flag.Bool("compact-aggregate", false, "Reduce aggregate.csv to the Name and Avg columns, in --unit")
+	rounding      = flag.String("rounding", "nearest", "Rounding of durations to two decimals: nearest, ceil or floor")
+	idle          = flag.Bool("idle", false, "Write the state machines without executions in the window to idle.csv")
+	listInput     = flag.String("list-input-json", "", "JSON object of extra ListExecutions parameters, e.g. {\"statusFilter\": \"FAILED\"}")
+	validate      = flag.Bool("validate", false, "Check the options, template and output directories, then exit without calling AWS")
+	linkChildren  = flag.Bool("link-children", false, "Read the history of every execution to link the child executions started by startExecution Task states, adding ChildExecutions, ParentName and ParentExecutionArn columns")
+	withMapItems  = flag.Bool("with-map-items", false, "Call ListMapRuns and DescribeMapRun per execution to add a MapItems column to the aggregate")
+	thousands     = flag.Bool("thousands-sep", false, "Group digits with commas in the Markdown, HTML and terminal outputs; CSV and JSON are unaffected")
+	healthFlag    = flag.Bool("health-score", false, "Add a 0-100 HealthScore column to the aggregate blending the success rate, the tail ratio and the outliers; implied by --health-weights and --sort-aggregate health")
+	healthWts     = flag.String("health-weights", "", "Comma-separated weights of the success rate, tail ratio and outlier signals of the health score (default 50,30,20)")
+	sortAggr      = flag.String("sort-aggregate", "name", "Order of the aggregate rows: name, or health for the least healthy first")
+	tiers         = flag.Bool("tiers", false, "Tag each execution fast, normal or slow in a Tier column and count the tiers in the aggregate; implied by --tier-fast and --tier-slow")
+	tierFast      = flag.Duration("tier-fast", 0, "Executions shorter than this are fast; defaults to the 25th percentile of each state machine")
+	tierSlow      = flag.Duration("tier-slow", 0, "Executions longer than this are slow; defaults to the 75th percentile of each state machine")
+	peakConc      = flag.Bool("peak-concurrency", false, "Add a PeakConcurrency column to the aggregate: the largest number of executions running at the same time")
+	utilization   = flag.Bool("utilization", false, "Add a Utilization column to the aggregate: the total execution time as a percentage of the measured window (two months, or the span of the --from-csv records); concurrent executions can push it above 100")
+	nameWidth     = flag.Int("name-width", 0, "Truncate state machine names to N characters with an ellipsis in the chart and Markdown tables; 0 keeps them whole")
+	watch         = flag.Duration("watch", 0, "Repeat the measurement at this interval, overwriting the outputs, until interrupted")
+	slowest       = flag.Int("slowest", 0, "Write the N slowest executions across all state machines to slowest.csv")
+	pctBand       = flag.String("percentile-band", "", "Keep only the executions between two duration percentiles of their state machine, e.g. 95-100 for the slowest 5%; every output sees only them")
+	fromCSV       = flag.String("from-csv", "", "Recompute the outputs from a previously written sfn.csv instead of calling AWS")
+	fromNDJSON    = flag.String("from-ndjson", "", "Recompute the outputs from a previously written sfn.ndjson, e.g. of --stream, instead of calling AWS; malformed lines are skipped")
+	expectedIntv  = flag.Duration("expected-interval", 0, "Write the intervals between consecutive starts per state machine to schedule.csv, counting longer intervals as gaps")
+	otlpEndpoint  = flag.String("otlp-endpoint", "", "OTLP/HTTP collector URL, e.g. http://localhost:4318, to export the per-machine duration gauges and execution counts to")
+	otlpTimeout   = flag.Duration("otlp-timeout", 10*time.Second, "Deadline of the --otlp-endpoint export")
+	htmlReport    = flag.Bool("html-report", false, "Write the aggregate table to report.html")
+	openReport    = flag.Bool("open", false, "Open report.html in the default browser after writing it; requires --html-report")
+	timeline      = flag.Bool("timeline", false, "Write the start and stop time of every execution to timeline.csv for a Gantt chart; requires --state-machine-arn")
+	stateBreak    = flag.Bool("state-breakdown", false, "Walk each execution's history and write the time spent per state to state-breakdown.csv; requires --state-machine-arn")
 )
 
 func main() {
 	flag.Parse()
 
-	if profile == nil || *profile == "" {
-		panic("profile is required")
+	if err := explainExpired(run()); err != nil {
+		if !*jsonErrors || writeJSONError(os.Stderr, err) != nil {
+			fmt.Fprintln(os.Stderr, "error:", err)
+		}
+		os.Exit(1)
+	}
+}
+
+// errGateFailed is returned after a threshold gate such as --max-p95 has
+// reported its failure.
+var errGateFailed = errors.New("one or more thresholds were exceeded")
+
+// now is the clock of the measurement window, the running durations and the
+// elapsed time, replaceable to run them at a fixed time.
+var now = time.Now
+
+func run() error {
+	started := now()
+
+	if *listProfs {
+		names, err := listProfiles()
+		if err != nil {
+			return err
+		}
+		for _, name := range names {
+			fmt.Println(name)
+		}
+		return nil
+	}
+
+	if *fieldsDoc {
+		u, err := parseUnit(*unit)
+		if err != nil {
+			return err
+		}
+		outputUnit = u
+		return printFieldsDoc(os.Stdout)
 	}
 
-	svc := createSfnSession(*profile)
+	// Flags take precedence over the standard AWS environment variables.
+	if *profile == "" {
+		*profile = os.Getenv("AWS_PROFILE")
+	}
+	if *region == "" {
+		*region = os.Getenv("AWS_REGION")
+	}
+	if *noSharedCfg && *configFile != "" {
+		return errors.New("--no-shared-config skips the file --config-file points to and cannot be combined with it")
+	}
+	if *noSharedCfg && *profile != "" {
+		return errors.New("--no-shared-config skips the file profiles are defined in and cannot be combined with a profile")
+	}
+	if *profile == "" && os.Getenv("AWS_ACCESS_KEY_ID") == "" && fromFileFlag() == "" && *envFlag == "" {
+		return errors.New("profile is required: set --profile or AWS_PROFILE, or provide static credentials")
+	}
 
-	machines, err := svc.ListStateMachines(&sfn.ListStateMachinesInput{})
+	loc, err := time.LoadLocation(*timezone)
 	if err != nil {
-		panic(err)
+		return err
+	}
+
+	if outputFormats, err = resolveFormats(*format, *out); err != nil {
+		return err
+	}
+
+	if business, err = parseBusinessHours(*busHours, *busDays, loc); err != nil {
+		return err
+	}
+	if percentileBand, err = parsePercentileBand(*pctBand); err != nil {
+		return err
 	}
 
-	records := SfnRecords{}
+	if *filterBy != "start" && *filterBy != "stop" {
+		return fmt.Errorf("unknown --filter-by: %s", *filterBy)
+	}
 
-	for _, machine := range machines.StateMachines {
-		executions, err := svc.ListExecutions(&sfn.ListExecutionsInput{
-			StateMachineArn: machine.StateMachineArn,
-		})
+	if *groupBy != "" && *groupBy != "month" {
+		return fmt.Errorf("unknown --group-by: %s", *groupBy)
+	}
+	if *groupFmt != "long" && *groupFmt != "wide" {
+		return fmt.Errorf("unknown --group-format: %s", *groupFmt)
+	}
+	if *lineEnding != "lf" && *lineEnding != "crlf" {
+		return fmt.Errorf("unknown --line-ending: %s", *lineEnding)
+	}
+	useCRLF = *lineEnding == "crlf"
+
+	if *stateBreak && *machineArn == "" {
+		return errors.New("--state-breakdown fetches the history of every execution and requires --state-machine-arn")
+	}
+	if *timeline && *machineArn == "" {
+		return errors.New("--timeline plots the executions of a single state machine and requires --state-machine-arn")
+	}
+
+	regions := splitList(*region)
+	profiles := splitList(*profile)
+	if *allRegions {
+		if len(regions) > 1 {
+			return errors.New("--all-regions discovers the regions and takes a single --region to call EC2 in")
+		}
+		if mode := fromFileFlag(); mode != "" {
+			return fmt.Errorf("--all-regions needs data only available from AWS and cannot be combined with %s", mode)
+		}
+	}
+	if *org {
+		if len(profiles) > 1 || *allRegions {
+			return errors.New("--org lists the accounts with a single --profile and takes explicit --region values")
+		}
+	}
+	var envs []environment
+	if *envFlag != "" {
+		if *profilesCfg == "" {
+			return errors.New("--env selects environments of --profiles-config, which is required")
+		}
+		if err := rejectFlags("--env", "is set per environment", envSetFlags); err != nil {
+			return err
+		}
+		all, err := readEnvironments(*profilesCfg)
 		if err != nil {
-			panic(err)
+			return err
+		}
+		if envs, err = selectEnvironments(all, splitList(*envFlag)); err != nil {
+			return err
+		}
+	}
+	if len(regions) > 1 || len(profiles) > 1 || *allRegions || *org || len(envs) > 1 {
+		mode := "several --profile or --region values"
+		switch {
+		case *allRegions:
+			mode = "--all-regions"
+		case *org:
+			mode = "--org"
+		case len(envs) > 1:
+			mode = "several --env values"
+		}
+		if err := rejectFlags(mode, "uses a single client", multiTargetIncompatible); err != nil {
+			return err
+		}
+	}
+
+	if mode := fromFileFlag(); mode != "" {
+		if *fromCSV != "" && *fromNDJSON != "" {
+			return errors.New("--from-csv and --from-ndjson are mutually exclusive")
+		}
+		if err := rejectFlags(mode, "needs data only available from AWS", fromCSVIncompatible); err != nil {
+			return err
+		}
+	}
+
+	if *executionArn != "" {
+		if err := rejectFlags("--execution-arn", "applies to listed executions", executionIncompatible); err != nil {
+			return err
+		}
+		if err := rejectFlags("--execution-arn", "writes an aggregate output", aggregateOutputs); err != nil {
+			return err
+		}
+		if len(outputFormats) > 1 {
+			return errors.New("--execution-arn prints to stdout in a single --format")
+		}
+		if outputFormats[0] == "parquet" {
+			return errors.New("--execution-arn prints a FailedState column, which --format parquet has no room for")
+		}
+	}
+
+	if *onlyRunning {
+		if *countOnly {
+			return errors.New("--count-only and --only-running are mutually exclusive")
+		}
+		if err := rejectFlags("--only-running", "needs finished executions", countOnlyIncompatible); err != nil {
+			return err
+		}
+		if err := rejectFlags("--only-running", "writes an aggregate output", aggregateOutputs); err != nil {
+			return err
+		}
+	}
+
+	if *countOnly {
+		if err := rejectFlags("--count-only", "needs execution records", countOnlyIncompatible); err != nil {
+			return err
+		}
+		if err := rejectFlags("--count-only", "writes an aggregate output", aggregateOutputs); err != nil {
+			return err
 		}
+	}
+
+	if *stream {
+		if err := rejectFlags("--stream", "needs every record in memory", streamIncompatible); err != nil {
+			return err
+		}
+	}
+	if *noAggregate {
+		if *onlyAggr {
+			return errors.New("--no-aggregate and --only-aggregate are mutually exclusive")
+		}
+		if err := rejectFlags("--no-aggregate", "writes an aggregate output", aggregateOutputs); err != nil {
+			return err
+		}
+	}
+
+	if *openReport && !*htmlReport {
+		return errors.New("--open requires --html-report")
+	}
 
-		for _, execution := range executions.Executions {
-			if execution.StartDate == nil || execution.StopDate == nil {
-				continue
+	include, err := readNameList(*includeFile)
+	if err != nil {
+		return err
+	}
+	exclude, err := readNameList(*excludeFile)
+	if err != nil {
+		return err
+	}
+
+	switch *nameStyle {
+	case measure.NameStyleName, measure.NameStyleNameVersion, measure.NameStyleARN:
+	default:
+		return fmt.Errorf("invalid --name-style: %s", *nameStyle)
+	}
+	if nameMap, err = readNameMap(*nameMapFile); err != nil {
+		return err
+	}
+	if groupExpr, err = parseGroupExpr(*groupByExpr); err != nil {
+		return err
+	}
+	if execNameGroup, err = parseExecNameGroup(*groupByExec); err != nil {
+		return err
+	}
+	if execNameGroup != nil {
+		switch {
+		case groupExpr != nil:
+			return errors.New("--group-by-expr and --group-by-execution-name are mutually exclusive")
+		case *stream:
+			return errors.New("--group-by-execution-name needs every record in memory and cannot be combined with --stream")
+		case fromFileFlag() != "":
+			// The records files do not hold the execution names.
+			return fmt.Errorf("--group-by-execution-name needs the execution names and cannot be combined with %s", fromFileFlag())
+		}
+	}
+
+	excludeStatuses, err := parseStatuses(*excludeStat)
+	if err != nil {
+		return err
+	}
+	if successStatuses, err = parseStatuses(*successStat); err != nil {
+		return err
+	}
+	if *excludeAbort {
+		excludeStatuses = append(excludeStatuses, sfn.ExecutionStatusAborted)
+	}
+
+	listExecutionsInput, err := parseListInput(*listInput)
+	if err != nil {
+		return err
+	}
+
+	edges, err := parseBucketEdges(*histEdges)
+	if err != nil {
+		return err
+	}
+
+	if outputUnit, err = parseUnit(*unit); err != nil {
+		return err
+	}
+	if !slices.Contains(durationFormats, *durFormat) {
+		return fmt.Errorf("unknown --duration-format: %s", *durFormat)
+	}
+	if durationFormat = *durFormat; !durationIsNumber() {
+		if *unit != "s" {
+			return fmt.Errorf("--duration-format %s carries its own units and cannot be combined with --unit", durationFormat)
+		}
+		// The strings carry their units, so headers and labels get none.
+		outputUnit = durationUnit{size: time.Second}
+	}
+	if outputRounding, err = parseRounding(*rounding); err != nil {
+		return err
+	}
+	thousandsSep = *thousands
+	if *sortAggr != "name" && *sortAggr != "health" {
+		return fmt.Errorf("unknown --sort-aggregate: %s", *sortAggr)
+	}
+	if *healthWts != "" {
+		if weights, err = parseHealthWeights(*healthWts); err != nil {
+			return err
+		}
+		*healthFlag = true
+	}
+	if *tierFast > 0 && *tierSlow > 0 && *tierFast > *tierSlow {
+		return fmt.Errorf("--tier-fast %s is longer than --tier-slow %s", *tierFast, *tierSlow)
+	}
+
+	if *nameWidth < 0 {
+		return fmt.Errorf("--name-width must not be negative: %d", *nameWidth)
+	}
+	maxNameWidth = *nameWidth
+
+	if startDateLayout, err = parseTimeFormat(*timeFormat); err != nil {
+		return err
+	}
+
+	if runID, err = parseRunID(*runIDFlag); err != nil {
+		return err
+	}
+
+	if *baselineJSON != "" {
+		b, err := readBaseline(*baselineJSON)
+		if err != nil {
+			return err
+		}
+		baselineRun = &b
+	}
+	if *postRequired && *postCommand == "" {
+		return errors.New("--post-required requires --post-command")
+	}
+	if *failRegress && baselineRun == nil {
+		return errors.New("--fail-on-regression requires --baseline-json")
+	}
+	if *regressOnly && baselineRun == nil {
+		return errors.New("--regressions-only requires --baseline-json")
+	}
+	if *regressMetric != "avg" && *regressMetric != "p95" {
+		return fmt.Errorf("unknown --regression-metric: %s", *regressMetric)
+	}
+	if sla, err = parseSLA(*slaFlag); err != nil {
+		return err
+	}
+	if *failSLA && sla == nil {
+		return errors.New("--fail-on-sla requires --sla")
+	}
+
+	topMetric, err := parseMetric(*topBy)
+	if err != nil {
+		return err
+	}
+
+	var tmpl *template.Template
+	if *tmplText != "" {
+		if tmpl, err = parseRecordTemplate(*tmplText); err != nil {
+			return err
+		}
+	}
+
+	changedSince, err := parseChangedSince(*changedFrom, loc)
+	if err != nil {
+		return err
+	}
+	if *changedByVer && changedSince.IsZero() {
+		return errors.New("--changed-by-version requires --changed-since")
+	}
+	if !changedSince.IsZero() && *machineArn != "" {
+		return errors.New("--changed-since cannot be combined with --state-machine-arn")
+	}
+
+	// The STS limits of an assumed-role session; the role's own maximum
+	// session duration may be lower.
+	if *roleDuration < 15*time.Minute || *roleDuration > 12*time.Hour {
+		return fmt.Errorf("invalid --assume-role-duration: %s: want 15m to 12h", *roleDuration)
+	}
+
+	if *validate {
+		if err := checkOutputDirs(); err != nil {
+			return err
+		}
+		fmt.Fprintln(os.Stderr, "options are valid")
+		return nil
+	}
+
+	if *outDir != "" {
+		if err := os.MkdirAll(*outDir, 0o755); err != nil {
+			return err
+		}
+		outputDir = *outDir
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if *timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, *timeout)
+		defer cancel()
+	}
+
+	if mode := fromFileFlag(); mode != "" {
+		read, path := readRecordsFile, *fromCSV
+		if mode == "--from-ndjson" {
+			read, path = readNDJSONFile, *fromNDJSON
+		}
+		records, err := read(path)
+		if err != nil {
+			return err
+		}
+		window = records.Span()
+		c := cycle{regions: regions, edges: edges, topMetric: topMetric, tmpl: tmpl}
+		return c.write(ctx, started, measure.Result{Records: records}, nil)
+	}
+
+	var retryOnCodes []string
+	if *retryOn != "" {
+		retryOnCodes = splitList(*retryOn)
+	}
+	cfg := sessionConfig{
+		Profile:            *profile,
+		Region:             *region,
+		Partition:          *partition,
+		RoleArn:            *roleArn,
+		MFASerial:          *mfaSerial,
+		MFAToken:           *mfaToken,
+		NonInteractive:     *nonInter,
+		NoSharedConfig:     *noSharedCfg,
+		ConfigFile:         *configFile,
+		CredentialsFile:    *credsFile,
+		RetryMode:          *retryMode,
+		MaxAttempts:        *maxAttempt,
+		RetryOn:            retryOnCodes,
+		STSRegion:          *stsRegion,
+		APITimeout:         *apiTimeout,
+		AssumeRoleDuration: *roleDuration,
+		InsecureSkipVerify: *insecure,
+	}
+	if *mfaToken != "" {
+		warnf("--mfa-token is stored in the shell history and visible in the process list; prefer the prompt outside scripts")
+	}
+	if *insecure {
+		// Not a warnf: --quiet must not hide that the connection is unverified.
+		fmt.Fprintln(os.Stderr, "INSECURE: --insecure-skip-verify disables TLS certificate verification; credentials and results can be intercepted")
+	}
+	if *whoami {
+		return printIdentities(ctx, os.Stdout, cfg, profiles, regions[0])
+	}
+
+	if *allRegions {
+		regions = nil
+	}
+	var targets []target
+	if *org {
+		targets, err = createOrgTargets(ctx, cfg, regions, *orgRole)
+	} else if envs != nil {
+		targets, err = createEnvTargets(ctx, cfg, envs)
+	} else {
+		targets, err = createTargets(ctx, cfg, profiles, regions)
+	}
+	if err != nil {
+		return err
+	}
+	if len(targets) == 0 {
+		return errors.New("no account to measure")
+	}
+	if envs != nil {
+		regions = targetRegions(targets)
+	}
+	if *allRegions {
+		regions = targetRegions(targets)
+		warnf("--all-regions: scanning %d regions, which multiplies the run time and API calls", len(regions))
+	}
+
+	for _, t := range targets {
+		if *rateLimit > 0 {
+			limitRate(t.client, *rateLimit)
+		}
+		countRetries(t.client)
+	}
+
+	if *executionArn != "" {
+		return measureExecution(ctx, targets[0].client, loc)
+	}
+
+	if needsDescribe() {
+		warnf("calling DescribeExecution once per execution, which can be slow and costly")
+	}
+	if *containsState != "" {
+		warnf("--contains-state: reading the history of every execution, which can be slow and costly")
+	}
+
+	if *changedByVer {
+		warnf("--changed-by-version: calling ListStateMachineVersions once per state machine created before --changed-since")
+	}
+
+	opts := measure.Options{
+		StateMachineArn:     *machineArn,
+		Include:             include,
+		Exclude:             exclude,
+		FilterByStop:        *filterBy == "stop",
+		Location:            loc,
+		Now:                 now,
+		MaxRunning:          *maxRunning,
+		KeepNegative:        *keepNegative,
+		Describe:            needsDescribe(),
+		ContainsState:       *containsState,
+		NameStyle:           *nameStyle,
+		ChangedSince:        changedSince,
+		ChangedByVersion:    *changedByVer,
+		Version:             *versionFilter,
+		Alias:               *aliasFilter,
+		ExcludeStatuses:     excludeStatuses,
+		ExecutionNamePrefix: *namePrefix,
+		ListExecutionsInput: listExecutionsInput,
+		MaxRecords:          *maxRecords,
+		Concurrency:         *concurrency,
+		Warnf:               warnf,
+		Listed:              warnScanTime,
+	}
+
+	if *onlyRunning {
+		if err := writeRunning(ctx, targets[0].client, opts); err != nil {
+			return err
+		}
+		if !*quiet {
+			fmt.Fprintf(os.Stderr, "Completed in %s\n", now().Sub(started).Round(time.Second))
+		}
+		return nil
+	}
+
+	if *countOnly {
+		opts.Since = now().AddDate(0, -2, 0)
+		if err := writeCounts(ctx, targets[0].client, opts); err != nil {
+			return err
+		}
+		if !*quiet {
+			fmt.Fprintf(os.Stderr, "Completed in %s\n", now().Sub(started).Round(time.Second))
+		}
+		return nil
+	}
+
+	c := cycle{
+		targets:   targets,
+		regions:   regions,
+		opts:      opts,
+		edges:     edges,
+		topMetric: topMetric,
+		tmpl:      tmpl,
+	}
+	if *watch <= 0 {
+		return c.collectAndWrite(ctx, started)
+	}
+
+	// Gate failures are reported every cycle but do not stop watching.
+	for cycleStarted := started; ; cycleStarted = now() {
+		if err := c.collectAndWrite(ctx, cycleStarted); err != nil && !errors.Is(err, errGateFailed) {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(*watch):
+		}
+	}
+}
+
+// cycle holds what a measurement needs that is prepared once per process, so
+// that --watch reuses the clients, and therefore the credentials, across
+// cycles.
+type cycle struct {
+	targets   []target
+	regions   []string
+	opts      measure.Options
+	edges     []time.Duration
+	topMetric func(measure.SfnRecords) time.Duration
+	tmpl      *template.Template
+}
+
+// perTarget reports whether the records are collected through
+// collectTargets, which tags them with their target's account and
+// environment. A single --env target goes through it too so that the Env
+// column is filled.
+func (c cycle) perTarget() bool {
+	return len(c.targets) > 1 || *org || *envFlag != ""
+}
+
+// collectAndWrite collects the executions and writes every output.
+func (c cycle) collectAndWrite(ctx context.Context, started time.Time) error {
+	// svc serves the features limited to a single target.
+	svc := c.targets[0].client
+	opts := c.opts
+	opts.Since = now().AddDate(0, -2, 0)
+	window = now().Sub(opts.Since)
+
+	if *stream {
+		result, err := runStream(ctx, c.targets[0], opts)
+		if err != nil {
+			return err
+		}
+		if *postCommand != "" {
+			if err := runPostCommand(ctx, *postCommand); err != nil {
+				return err
 			}
+		}
+		return finish(started, result)
+	}
 
-			if execution.StartDate.Before(time.Now().AddDate(0, -2, 0)) {
-				continue
+	var result measure.Result
+	var err error
+	if c.perTarget() {
+		result, err = collectTargets(ctx, c.targets, opts, *profileConc)
+	} else {
+		result, err = measure.Collect(ctx, svc, opts)
+	}
+	records := result.Records
+	if ctx.Err() != nil {
+		fmt.Fprintf(os.Stderr, "interrupted (%v): writing partial results (%d records)\n", ctx.Err(), len(records))
+	} else if err != nil {
+		return err
+	}
+
+	if *assertWindow {
+		if err := checkWindow(records, opts.Since, now()); err != nil {
+			return err
+		}
+	}
+
+	if *withMapItems && ctx.Err() == nil {
+		warnf("calling ListMapRuns once per execution, which can be slow and costly")
+		for i, err := range measure.CountMapItems(ctx, svc, records, *concurrency) {
+			if err != nil && ctx.Err() == nil {
+				warnf("counting map items of %s: %v", records[i].ExecutionArn, err)
+			}
+		}
+	}
+
+	if *linkChildren && ctx.Err() == nil {
+		warnf("--link-children: reading the history of every execution, which can be slow and costly")
+		for i, err := range measure.LinkChildren(ctx, svc, records, *concurrency) {
+			if err != nil && ctx.Err() == nil {
+				warnf("linking the child executions of %s: %v", records[i].ExecutionArn, err)
+			}
+		}
+	}
+
+	if *failureSum && !needsDescribe() && ctx.Err() == nil {
+		warnf("calling DescribeExecution once per failed execution, which can be slow and costly")
+		for i, err := range measure.DescribeFailures(ctx, svc, records, *concurrency) {
+			if err != nil && ctx.Err() == nil {
+				warnf("describing the failure of %s: %v", records[i].ExecutionArn, err)
 			}
+		}
+	}
+
+	return c.write(ctx, started, result, svc)
+}
+
+// write writes every output of the collected result. svc is only used by the
+// features limited to a single target.
+func (c cycle) write(ctx context.Context, started time.Time, result measure.Result, svc measure.Client) error {
+	if percentileBand != nil {
+		result.Records = result.Records.PercentileBand(percentileBand.low, percentileBand.high)
+	}
+	records := result.Records
+
+	if tiersEnabled() {
+		prepareTiers(records)
+	}
+	if *relative {
+		prepareMedians(records)
+	}
 
-			duration := execution.StopDate.Sub(*execution.StartDate)
+	if !*onlyAggr {
+		for _, format := range outputFormats {
+			if err := createRecordsFile(recordsPath(format), format, records, recordColumns()); err != nil {
+				return err
+			}
+		}
+	}
 
-			name := strings.Split(*machine.StateMachineArn, ":")[6]
+	if *splitStatus {
+		if err := createPerStatusCsvFiles(records, recordColumns()); err != nil {
+			return err
+		}
+	}
 
-			records = append(records, SfnRecord{
-				Name:      name,
-				StartDate: execution.StartDate.Format(time.DateOnly),
-				Duration:  duration,
-				Status:    *execution.Status,
-			})
+	if *slowest > 0 {
+		if err := createSlowestCsvFile(records.Slowest(*slowest)); err != nil {
+			return err
 		}
 	}
 
-	if err := createCsvFile(records); err != nil {
-		panic(err)
+	if *failureSum {
+		if err := createFailureSummaryCsvFile(records); err != nil {
+			return err
+		}
 	}
 
-	if err := records.aggregate(); err != nil {
-		panic(err)
+	if len(c.regions) > 1 {
+		if err := createRegionCsvFile(records); err != nil {
+			return err
+		}
 	}
+
+	if c.tmpl != nil {
+		if err := renderTemplate(os.Stdout, c.tmpl, records); err != nil {
+			return err
+		}
+	}
+
+	if zeros := records.Len() - records.NonZero().Len(); zeros > 0 {
+		if *minNonZero {
+			warnf("--min-nonzero-duration: leaving %d zero-duration executions out of the stats", zeros)
+		} else {
+			warnf("%d executions have a zero duration, which pulls Min to 0; --min-nonzero-duration leaves them out of the stats", zeros)
+		}
+	}
+
+	if !*noAggregate {
+		if err := writeAggregates(ctx, svc, statsRecords(records), c.edges, c.topMetric); err != nil {
+			return err
+		}
+	}
+
+	if *timeline {
+		if err := createTimelineCsvFile(records); err != nil {
+			return err
+		}
+	}
+
+	if *stateBreak && ctx.Err() == nil {
+		stats, err := measure.StateBreakdown(ctx, svc, records)
+		if err != nil {
+			return err
+		}
+		if err := createStateBreakdownCsvFile(stats); err != nil {
+			return err
+		}
+	}
+
+	if *postCommand != "" {
+		if err := runPostCommand(ctx, *postCommand); err != nil {
+			return err
+		}
+	}
+
+	return finish(started, result)
 }
 
-type SfnRecord struct {
-	Name      string        `csv:"Name"`
-	StartDate string        `csv:"StartDate"`
-	Duration  time.Duration `csv:"Duration"`
-	Status    string        `csv:"Status"`
+// writeAggregates writes aggregate.csv and every output derived from the
+// records grouped by state machine.
+func writeAggregates(ctx context.Context, svc measure.Client, records measure.SfnRecords, edges []time.Duration, topMetric func(measure.SfnRecords) time.Duration) error {
+	aggregated := records.AggregateBy(groupKey)
+
+	if *perMachine != "" {
+		if err := createPerMachineCsvFiles(outputPath(*perMachine), aggregated, recordColumns()); err != nil {
+			return err
+		}
+	}
+
+	if *minExecutions > 0 {
+		aggregated = aggregated.Filter(func(_ string, r measure.SfnRecords) bool { return r.Len() >= *minExecutions })
+	}
+	if *top > 0 {
+		aggregated = aggregated.Top(*top, topMetric)
+	}
+	if err := createAggregateCsvFile(aggregated); err != nil {
+		return err
+	}
+	if *aggrJSON {
+		if err := createAggregateJSONFile(aggregated); err != nil {
+			return err
+		}
+	}
+
+	if *groupBy == "month" {
+		if err := createMonthlyCsvFile(aggregated, *groupFmt == "wide"); err != nil {
+			return err
+		}
+	}
+
+	if *tailThreshold > 0 {
+		if err := createTailHeavyCsvFile(aggregated, *tailThreshold); err != nil {
+			return err
+		}
+	}
+
+	if *statusCounts {
+		if err := createStatusCountsCsvFile(aggregated); err != nil {
+			return err
+		}
+	}
+
+	if *statusSet {
+		if err := createStatusesCsvFile(aggregated); err != nil {
+			return err
+		}
+	}
+
+	if *statusTrend {
+		if err := createStatusTrendCsvFile(aggregated); err != nil {
+			return err
+		}
+	}
+
+	if *weekdayWknd {
+		if err := createWeekdayWeekendCsvFile(aggregated); err != nil {
+			return err
+		}
+	}
+
+	if *histogram {
+		if err := createHistogramCsvFile(aggregated, edges); err != nil {
+			return err
+		}
+	}
+
+	if *expectedIntv > 0 {
+		if err := createScheduleCsvFile(aggregated, *expectedIntv); err != nil {
+			return err
+		}
+	}
+
+	if *groupByTagKey != "" {
+		tags, err := fetchTags(ctx, svc, aggregated, tagCache{dir: *cacheDir, ttl: *tagCacheTTL, compress: *cacheGzip})
+		if err != nil {
+			return err
+		}
+		if err := createGroupedCsvFile(outputPath("by-tag.csv"), "TagValue", groupByTag(aggregated, *groupByTagKey, tags), aggregateColumns(), nil); err != nil {
+			return err
+		}
+	}
+
+	if *byAlias {
+		if err := createByAliasCsvFile(aggregated); err != nil {
+			return err
+		}
+	}
+
+	if *htmlReport {
+		if err := createHTMLReport(aggregated); err != nil {
+			return err
+		}
+		if *openReport {
+			openInBrowser(outputPath(htmlReportPath))
+		}
+	}
+
+	if *ghSummary {
+		if err := writeGitHubSummary(aggregated); err != nil {
+			return err
+		}
+	}
+
+	if *otlpEndpoint != "" {
+		if err := exportOTLP(ctx, *otlpEndpoint, aggregated); err != nil {
+			return err
+		}
+	}
+
+	if *chart && !*quiet && !recordsToStdout() {
+		if err := printChart(os.Stdout, aggregated, terminalWidth()); err != nil {
+			return err
+		}
+	}
+
+	if *sparkLine && !*quiet && !recordsToStdout() {
+		if err := printSparklines(os.Stdout, aggregated); err != nil {
+			return err
+		}
+	}
+
+	return nil
 }
 
-func (r SfnRecord) StringDurationSecond() string {
-	return fmt.Sprintf("%.2f", r.Duration.Seconds())
+// statsRecords returns the records the aggregate stats and gates are computed
+// on. --skip-first leaves out the warmup executions of each state machine,
+// and --min-nonzero-duration the zero-duration executions, which would
+// otherwise pull Min and the low percentiles to 0; the records file and the
+// status summary keep them.
+func statsRecords(records measure.SfnRecords) measure.SfnRecords {
+	records = records.SkipFirst(*skipFirst)
+	if !*minNonZero {
+		return records
+	}
+	return records.NonZero()
 }
 
-type SfnRecords []SfnRecord
+// finish writes idle.csv, runs the threshold gates and prints the run summary.
+func finish(started time.Time, result measure.Result) error {
+	records := result.Records
+
+	if *idle {
+		if err := createIdleCsvFile(result.Idle); err != nil {
+			return err
+		}
+	}
+
+	if len(result.Anomalies) > 0 {
+		if err := createAnomaliesCsvFile(result.Anomalies); err != nil {
+			return err
+		}
+		warnf("%d finished executions had no start or stop date and were left out; see anomalies.csv", len(result.Anomalies))
+	}
+
+	if result.Truncated {
+		// Not a warnf: --quiet must not hide that the stats may be biased.
+		fmt.Fprintf(os.Stderr, "TRUNCATED: stopped after --max-records %d; the results miss executions and the stats may be biased\n", *maxRecords)
+	}
+
+	// Every gate reports its own failure so that they can all trip at once.
+	gateFailed := false
+
+	if failed, err := reportStuck(result.Stuck); err != nil {
+		return err
+	} else if failed {
+		gateFailed = true
+	}
+
+	if *maxP95 > 0 {
+		if p95 := statsRecords(records).Percentile(95); p95 > *maxP95 {
+			fmt.Fprintf(os.Stderr, "p95 duration %s exceeds --max-p95 %s\n", p95, *maxP95)
+			gateFailed = true
+		}
+	}
 
-func (r SfnRecords) MaxDuration() time.Duration {
-	max := r[0].Duration
-	for _, record := range r {
-		if record.Duration > max {
-			max = record.Duration
+	if baselineRun != nil {
+		diff := diffBaseline(*baselineRun, statsRecords(records))
+		write := createDiffJSONFile
+		if *regressOnly {
+			write = createRegressionsCsvFile
+		}
+		if err := write(diff); err != nil {
+			return err
+		}
+		if reportRegressions(os.Stderr, diff) > 0 && (*failRegress || *regressOnly) {
+			gateFailed = true
 		}
 	}
-	return max
+
+	if sla != nil && (*failSLA || !*quiet) {
+		aggregated := statsRecords(records).AggregateBy(groupKey)
+		if reportSLABreaches(os.Stderr, aggregated) > 0 && *failSLA {
+			gateFailed = true
+		}
+	}
+
+	retries, throttled := takeAPIStats()
+	if *summaryJSON {
+		if err := createSummaryJSONFile(result, retries, throttled, now().Sub(started)); err != nil {
+			return err
+		}
+	}
+
+	if *manifest {
+		if err := createManifestFile(); err != nil {
+			return err
+		}
+	}
+
+	if !*quiet {
+		printStatusSummary(os.Stderr, records)
+		if len(result.Idle) > 0 {
+			fmt.Fprintf(os.Stderr, "%d state machines had no executions in the window\n", len(result.Idle))
+		}
+		if fromFileFlag() == "" {
+			// High counts call for a lower --concurrency or --rate-limit.
+			fmt.Fprintf(os.Stderr, "API: retries=%d, throttled=%d\n", retries, throttled)
+		}
+		fmt.Fprintf(os.Stderr, "Completed in %s\n", now().Sub(started).Round(time.Second))
+	}
+	if gateFailed {
+		return errGateFailed
+	}
+	return nil
 }
 
-func (r SfnRecords) MinDuration() time.Duration {
-	min := r[0].Duration
-	for _, record := range r {
-		if record.Duration < min {
-			min = record.Duration
+// aggregateOutputs lists the flags enabling outputs derived from the
+// aggregate, which --no-aggregate skips.
+var aggregateOutputs = map[string]bool{
+	"per-machine-dir":   true,
+	"tail-threshold":    true,
+	"min-executions":    true,
+	"top":               true,
+	"group-by":          true,
+	"statuses":          true,
+	"status-trend":      true,
+	"weekday-weekend":   true,
+	"histogram":         true,
+	"expected-interval": true,
+	"group-by-tag":      true,
+	"by-alias":          true,
+	"html-report":       true,
+	"github-summary":    true,
+	"otlp-endpoint":     true,
+	"chart":             true,
+	"sparkline":         true,
+}
+
+// envSetFlags lists the flags each --env environment sets itself.
+var envSetFlags = map[string]bool{
+	"profile":     true,
+	"region":      true,
+	"org":         true,
+	"all-regions": true,
+	"whoami":      true,
+}
+
+// rejectFlags returns an error naming the first flag set on the command line
+// that is in names and therefore conflicts with mode.
+func rejectFlags(mode, reason string, names map[string]bool) error {
+	var err error
+	flag.Visit(func(f *flag.Flag) {
+		if err == nil && names[f.Name] {
+			err = fmt.Errorf("--%s %s and cannot be combined with %s", f.Name, reason, mode)
 		}
+	})
+	return err
+}
+
+// reportStuck writes stuck.csv when --max-running is set and reports whether
+// the --fail-on-stuck gate failed.
+func reportStuck(stuck []measure.StuckExecution) (bool, error) {
+	if *maxRunning <= 0 {
+		return false, nil
+	}
+	if err := createStuckCsvFile(stuck); err != nil {
+		return false, err
+	}
+	if *failStuck && len(stuck) > 0 {
+		fmt.Fprintf(os.Stderr, "%d executions have been running longer than %s\n", len(stuck), *maxRunning)
+		return true, nil
 	}
-	return min
+	return false, nil
 }
 
-func (r SfnRecords) AvgDuration() time.Duration {
-	total := time.Duration(0)
-	for _, record := range r {
-		total += record.Duration
+// needsDescribe reports whether the flags require a DescribeExecution call per
+// execution.
+func needsDescribe() bool {
+	return *withIO || *withVersion || *versionFilter != "" || *aliasFilter != "" || *byAlias || *nameStyle == measure.NameStyleNameVersion
+}
+
+// warnf prints a warning to stderr unless --quiet is set.
+func warnf(format string, args ...any) {
+	if !*quiet {
+		fmt.Fprintf(os.Stderr, "warning: "+format+"\n", args...)
 	}
-	return total / time.Duration(len(r))
 }
 
-func (r SfnRecords) Len() int {
-	return len(r)
+// columnType tells typed outputs such as JSON how to encode a column value.
+type columnType string
+
+const (
+	columnString  columnType = "string"
+	columnInteger columnType = "integer"
+	columnNumber  columnType = "number"
+	// columnDuration is a number in the unit selected by --unit.
+	columnDuration columnType = "duration"
+)
+
+type recordColumn struct {
+	Header string
+	Type   columnType
+	Value  func(measure.SfnRecord) string
+	// Doc describes the column for --fields-doc.
+	Doc string
 }
 
-func createCsvFile(records SfnRecords) error {
-	w, err := os.Create("sfn.csv")
+// recordColumns returns the columns of the records file enabled by the flags,
+// or every column with --fields-doc.
+func recordColumns() []recordColumn {
+	columns := []recordColumn{
+		{"Name", columnString, func(r measure.SfnRecord) string { return r.Name }, "State machine name"},
+		{"StartDate", columnString, formatStartDate, "Start of the execution in --timezone, formatted with --time-format"},
+		{durationHeader("Duration"), columnDuration, func(r measure.SfnRecord) string { return formatDuration(r.Duration) }, "Time from the start to the stop of the execution"},
+		{"Status", columnString, func(r measure.SfnRecord) string { return r.Status }, "Execution status, e.g. SUCCEEDED or FAILED"},
+		// StartTimestamp follows the original columns so that positional
+		// readers of StartDate keep working.
+		{"StartTimestamp", columnString, func(r measure.SfnRecord) string { return r.StartTime.Format(time.RFC3339Nano) }, "Full RFC 3339 start time in --timezone"},
+	}
+	if columnEnabled(*withIO) {
+		columns = append(columns,
+			recordColumn{"InputBytes", columnInteger, func(r measure.SfnRecord) string { return strconv.FormatInt(r.InputBytes, 10) }, "Size of the execution input in bytes (--with-io-size)"},
+			recordColumn{"OutputBytes", columnInteger, func(r measure.SfnRecord) string { return strconv.FormatInt(r.OutputBytes, 10) }, "Size of the execution output in bytes (--with-io-size)"},
+		)
+	}
+	if columnEnabled(*envFlag != "") {
+		columns = append(columns, recordColumn{"Env", columnString, func(r measure.SfnRecord) string { return r.Env }, "Environment of --profiles-config the execution was collected from (--env)"})
+	}
+	if columnEnabled(*org) {
+		columns = append(columns,
+			recordColumn{"AccountId", columnString, func(r measure.SfnRecord) string { return r.Account }, "AWS account ID of the state machine (--org)"},
+			recordColumn{"AccountName", columnString, func(r measure.SfnRecord) string { return r.AccountName }, "AWS account name of the state machine (--org)"},
+		)
+	}
+	if columnEnabled(*withArn) {
+		columns = append(columns, recordColumn{"StateMachineArn", columnString, func(r measure.SfnRecord) string { return r.StateMachineArn }, "State machine ARN (--with-arn)"})
+	}
+	if columnEnabled(business != nil) {
+		columns = append(columns, recordColumn{durationHeader("BusinessDuration"), columnDuration, businessDuration, "Part of the execution within --business-hours on --business-days, in --timezone"})
+	}
+	if columnEnabled(tiersEnabled()) {
+		columns = append(columns, recordColumn{"Tier", columnString, tierOf, "fast, normal or slow against the tier thresholds (--tiers)"})
+	}
+	if columnEnabled(*relative) {
+		columns = append(columns, recordColumn{"PctOfMedian", columnNumber, pctOfMedian, "Duration as a percentage of the state machine's median; empty for a zero median (--relative)"})
+	}
+	if columnEnabled(*withVersion) {
+		columns = append(columns, recordColumn{"Version", columnString, func(r measure.SfnRecord) string { return r.Version }, "State machine version the execution ran (--with-version)"})
+	}
+	if columnEnabled(*linkChildren) {
+		columns = append(columns,
+			recordColumn{"ChildExecutions", columnInteger, func(r measure.SfnRecord) string { return strconv.Itoa(r.ChildExecutions) }, "Executions started by startExecution Task states (--link-children)"},
+			recordColumn{"ParentName", columnString, func(r measure.SfnRecord) string { return r.ParentName }, "State machine of the execution that started this one (--link-children)"},
+			recordColumn{"ParentExecutionArn", columnString, func(r measure.SfnRecord) string { return r.ParentExecutionArn }, "Execution that started this one (--link-children)"},
+		)
+	}
+	return columns
+}
+
+func createCsvFile(path string, records measure.SfnRecords, columns []recordColumn) error {
+	return createDelimitedFile(path, records, columns, ',')
+}
+
+// createDelimitedFile writes the records to path as CSV with the given field
+// delimiter.
+func createDelimitedFile(path string, records measure.SfnRecords, columns []recordColumn, comma rune) error {
+	w, err := createOutput(path)
 	if err != nil {
 		return err
 	}
 	defer w.Close()
-	writer := csv.NewWriter(w)
+	if err := writeDelimited(w, records, columns, comma); err != nil {
+		return err
+	}
+	return w.Close()
+}
 
-	if err := writer.Write([]string{"Name", "StartDate", "Duration", "Status"}); err != nil {
+// writeDelimited writes the records to w, which need not be a file: output
+// going to a FIFO or /dev/stdout goes through the same path.
+func writeDelimited(w io.Writer, records measure.SfnRecords, columns []recordColumn, comma rune) error {
+	writer, err := newCsvWriter(w)
+	if err != nil {
 		return err
 	}
+	writer.Comma = comma
+
+	if !*noHeader {
+		header := make([]string, len(columns))
+		for i, column := range columns {
+			header[i] = column.Header
+		}
+		if err := writer.Write(header); err != nil {
+			return err
+		}
+	}
 
 	for _, record := range records {
-		if err := writer.Write([]string{record.Name, record.StartDate, record.StringDurationSecond(), record.Status}); err != nil {
+		row := make([]string, len(columns))
+		for i, column := range columns {
+			row[i] = column.Value(record)
+		}
+		if err := writer.Write(row); err != nil {
 			return err
 		}
 	}
@@ -140,62 +1324,191 @@ func createCsvFile(records SfnRecords) error {
 	return writer.Error()
 }
 
-func createSfnSession(profile string) *sfn.SFN {
-	opt := session.Options{
-		Config:                  *aws.NewConfig(),
-		Profile:                 profile,
-		AssumeRoleTokenProvider: stscreds.StdinTokenProvider,
-		AssumeRoleDuration:      3600 * time.Second,
-		SharedConfigState:       session.SharedConfigEnable,
+// parseMetric maps a metric name to the stat method computing it.
+func parseMetric(name string) (func(measure.SfnRecords) time.Duration, error) {
+	switch name {
+	case "max":
+		return measure.SfnRecords.MaxDuration, nil
+	case "avg":
+		return measure.SfnRecords.AvgDuration, nil
+	case "p95":
+		return func(r measure.SfnRecords) time.Duration { return r.Percentile(95) }, nil
 	}
-	sess := session.Must(session.NewSessionWithOptions(opt))
+	return nil, fmt.Errorf("unknown metric: %s", name)
+}
+
+// successStatuses is set by --success-statuses.
+var successStatuses []string
+
+// parseStatuses splits a comma-separated list of execution statuses,
+// rejecting unknown ones.
+func parseStatuses(list string) ([]string, error) {
+	if list == "" {
+		return nil, nil
+	}
+
+	var statuses []string
+	for _, status := range strings.Split(list, ",") {
+		status = strings.ToUpper(strings.TrimSpace(status))
+		if !slices.Contains(sfn.ExecutionStatus_Values(), status) {
+			return nil, fmt.Errorf("unknown execution status: %s", status)
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+// window is the length of time the records were collected over, which
+// Utilization is relative to. For --from-csv it is the span of the records,
+// as the original window is unknown.
+var window time.Duration
 
-	return sfn.New(sess)
+type aggregateColumn struct {
+	Header string
+	Type   columnType
+	Value  func(measure.SfnRecords) string
+	// Doc describes the column for --fields-doc.
+	Doc string
 }
 
-type AggregatedRecordMap map[string]SfnRecords
+// aggregateColumns returns the stat columns of aggregate.csv, which follow the
+// Name column, or every column with --fields-doc.
+func aggregateColumns() []aggregateColumn {
+	columns := []aggregateColumn{
+		{durationHeader("Max"), columnDuration, func(r measure.SfnRecords) string { return formatDuration(r.MaxDuration()) }, "Longest duration"},
+		{durationHeader("Min"), columnDuration, func(r measure.SfnRecords) string { return formatDuration(r.MinDuration()) }, "Shortest duration"},
+		{durationHeader("Avg"), columnDuration, func(r measure.SfnRecords) string { return formatDuration(r.AvgDuration()) }, "Mean duration"},
+		{"Len", columnInteger, func(r measure.SfnRecords) string { return strconv.Itoa(r.Len()) }, "Number of executions"},
+		{"CV", columnNumber, func(r measure.SfnRecords) string { return fmt.Sprintf("%.2f", r.CoefficientOfVariation()) }, "Coefficient of variation: the standard deviation over the mean"},
+		{"ActiveDays", columnInteger, func(r measure.SfnRecords) string { return strconv.Itoa(r.ActiveDays()) }, "Number of distinct start dates"},
+		{"TailRatio", columnNumber, func(r measure.SfnRecords) string { return fmt.Sprintf("%.2f", r.TailRatio()) }, "95th percentile over the mean; well above 1 for a long tail"},
+		{"WeightedSuccessRate", columnNumber, func(r measure.SfnRecords) string {
+			return fmt.Sprintf("%.2f", r.WeightedSuccessRate(successStatuses...))
+		}, "Fraction of the total duration spent in executions with a --success-statuses status"},
+		{"SlowestExecution", columnString, func(r measure.SfnRecords) string { return executionLabel(r.MaxExecution()) }, "Name and start date of the longest execution"},
+		{"FastestExecution", columnString, func(r measure.SfnRecords) string { return executionLabel(r.MinExecution()) }, "Name and start date of the shortest execution"},
+	}
+	if columnEnabled(*withArn) {
+		columns = append(columns, aggregateColumn{"StateMachineArn", columnString, stateMachineArns, "Semicolon-separated state machine ARNs of the group (--with-arn)"})
+	}
+	if columnEnabled(sla != nil) {
+		columns = append(columns, aggregateColumn{"SLAMet", columnString, func(r measure.SfnRecords) string { return strconv.FormatBool(sla.met(r)) }, "Whether the --sla percentile is within its target"})
+	}
+	if columnEnabled(healthEnabled()) {
+		columns = append(columns, aggregateColumn{"HealthScore", columnNumber, func(r measure.SfnRecords) string {
+			return fmt.Sprintf("%.1f", healthScore(r, weights))
+		}, "0-100 blend of the success rate, tail ratio and outliers per --health-weights (--health-score)"})
+	}
+	if columnEnabled(tiersEnabled()) {
+		for _, tier := range []struct{ header, name string }{{"Fast", "fast"}, {"Normal", "normal"}, {"Slow", "slow"}} {
+			columns = append(columns, aggregateColumn{tier.header, columnInteger, func(r measure.SfnRecords) string { return strconv.Itoa(countTier(r, tier.name)) }, "Number of " + tier.name + " executions (--tiers)"})
+		}
+	}
+	if columnEnabled(*peakConc) {
+		columns = append(columns, aggregateColumn{"PeakConcurrency", columnInteger, func(r measure.SfnRecords) string {
+			return strconv.Itoa(r.PeakConcurrency())
+		}, "Largest number of executions running at the same time (--peak-concurrency)"})
+	}
+	if columnEnabled(*utilization) {
+		columns = append(columns, aggregateColumn{"Utilization", columnNumber, func(r measure.SfnRecords) string {
+			return fmt.Sprintf("%.2f", r.Utilization(window)*100)
+		}, "Total duration as a percentage of the measured window (--utilization)"})
+	}
+	if columnEnabled(*withMapItems) {
+		columns = append(columns, aggregateColumn{"MapItems", columnInteger, func(r measure.SfnRecords) string {
+			// Left empty for state machines that never started a map run.
+			if items, mapped := r.MapItems(); mapped {
+				return strconv.FormatInt(items, 10)
+			}
+			return ""
+		}, "Items processed by Distributed Map runs (--with-map-items)"})
+	}
+	return columns
+}
 
-func (r *SfnRecords) aggregate() error {
-	aggregated := make(AggregatedRecordMap)
-	for _, record := range *r {
-		aggregated[record.Name] = append(aggregated[record.Name], record)
+// stateMachineArns lists the distinct state machine ARNs of the records in
+// lexical order, separated by semicolons. A group has several when the same
+// name exists in more than one region or account.
+func stateMachineArns(records measure.SfnRecords) string {
+	var arns []string
+	for _, record := range records {
+		if record.StateMachineArn != "" {
+			arns = append(arns, record.StateMachineArn)
+		}
 	}
+	slices.Sort(arns)
+	return strings.Join(slices.Compact(arns), ";")
+}
 
-	if err := createAggregateCsvFile(aggregated); err != nil {
-		return err
+// executionLabel identifies an execution in the aggregate as "name (date)".
+func executionLabel(r measure.SfnRecord) string {
+	if r.ExecutionName == "" {
+		return ""
 	}
+	return fmt.Sprintf("%s (%s)", r.ExecutionName, r.StartDate)
+}
 
-	return nil
+// createAggregateCsvFile writes aggregate.csv, reduced to the Avg column with
+// --compact-aggregate.
+func createAggregateCsvFile(records measure.AggregatedRecordMap) error {
+	columns := aggregateColumns()
+	if *compactAggr {
+		columns = slices.DeleteFunc(columns, func(c aggregateColumn) bool { return c.Header != durationHeader("Avg") })
+	}
+	return createGroupedCsvFile(outputPath("aggregate.csv"), "Name", records, columns, groupName)
 }
 
-func createAggregateCsvFile(records AggregatedRecordMap) error {
-	w, err := os.Create("aggregate.csv")
+// createGroupedCsvFile writes the given aggregate columns per group, keyed by a
+// first column labelled keyHeader. label, when non-nil, renders that column
+// from the group key and records instead of the key itself.
+func createGroupedCsvFile(path, keyHeader string, records measure.AggregatedRecordMap, columns []aggregateColumn, label func(string, measure.SfnRecords) string) error {
+	w, err := createOutput(path)
 	if err != nil {
 		return err
 	}
 	defer w.Close()
-	writer := csv.NewWriter(w)
-
-	if err := writer.Write([]string{"Name", "Max", "Min", "Avg", "Len"}); err != nil {
+	writer, err := newCsvWriter(w)
+	if err != nil {
 		return err
 	}
 
-	for name, records := range records {
-		if err := writer.Write([]string{
-			name,
-			durationToSeconfString(records.MaxDuration()),
-			durationToSeconfString(records.MinDuration()),
-			durationToSeconfString(records.AvgDuration()),
-			fmt.Sprintf("%d", records.Len()),
-		}); err != nil {
-			return err
+	header := []string{keyHeader}
+	for _, column := range columns {
+		header = append(header, column.Header)
+	}
+	rows := [][]string{header}
+	for _, name := range aggregateNames(records) {
+		row := []string{name}
+		if label != nil {
+			row[0] = label(name, records[name])
+		}
+		for _, column := range columns {
+			row = append(row, column.Value(records[name]))
 		}
+		rows = append(rows, row)
 	}
 
-	writer.Flush()
-	return writer.Error()
+	if *transpose {
+		rows = transposeRows(rows)
+	}
+	if *noHeader {
+		rows = rows[1:]
+	}
+	return writer.WriteAll(rows)
 }
 
-func durationToSeconfString(d time.Duration) string {
-	return fmt.Sprintf("%.2f", d.Seconds())
+// transposeRows pivots a table so that its columns become rows, e.g. for
+// --transpose one row per metric and one column per state machine.
+func transposeRows(rows [][]string) [][]string {
+	if len(rows) == 0 {
+		return rows
+	}
+	transposed := make([][]string, len(rows[0]))
+	for i := range transposed {
+		transposed[i] = make([]string, len(rows))
+		for j, row := range rows {
+			transposed[i][j] = row[i]
+		}
+	}
+	return transposed
 }
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Finatext/measure-sfn/measure"
+)
+
+func TestWriteDelimitedToPipe(t *testing.T) {
+	records := measure.SfnRecords{
+		{Name: "a", Duration: time.Second},
+		{Name: "b", Duration: 2 * time.Second},
+	}
+	columns := []recordColumn{
+		{Header: "Name", Value: func(r measure.SfnRecord) string { return r.Name }},
+		{Header: "Duration", Value: func(r measure.SfnRecord) string { return r.Duration.String() }},
+	}
+
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(writeDelimited(pw, records, columns, '\t'))
+	}()
+	got, err := io.ReadAll(pr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "Name\tDuration\na\t1s\nb\t2s\n"; string(got) != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestStatsRecordsMinNonZero(t *testing.T) {
+	records := measure.SfnRecords{{Duration: 0}, {Duration: time.Second}}
+	defer func(v bool) { *minNonZero = v }(*minNonZero)
+
+	*minNonZero = false
+	if got := statsRecords(records).Len(); got != 2 {
+		t.Errorf("got %d records, want the zero-duration one kept", got)
+	}
+	*minNonZero = true
+	if got := statsRecords(records).MinDuration(); got != time.Second {
+		t.Errorf("MinDuration() = %s with --min-nonzero-duration, want 1s", got)
+	}
+}
+
+func TestGroupKeyWithArn(t *testing.T) {
+	east := measure.SfnRecord{Name: "a", StateMachineArn: "arn:aws:states:us-east-1:111111111111:stateMachine:a"}
+	west := measure.SfnRecord{Name: "a", StateMachineArn: "arn:aws:states:eu-west-1:222222222222:stateMachine:a"}
+	defer func(v bool) { *withArn = v }(*withArn)
+
+	*withArn = false
+	if groupKey(east) != groupKey(west) {
+		t.Errorf("without --with-arn, got keys %q and %q, want the same name", groupKey(east), groupKey(west))
+	}
+	*withArn = true
+	if groupKey(east) == groupKey(west) {
+		t.Errorf("with --with-arn, both records got key %q", groupKey(east))
+	}
+	if got := groupKey(measure.SfnRecord{Name: "a"}); got != "a" {
+		t.Errorf("with --with-arn and no ARN, got key %q, want the name", got)
+	}
+
+	defer func(rules []nameRule) { nameMap = rules }(nameMap)
+	nameMap = []nameRule{{regexp.MustCompile("^a$"), "mapped"}}
+	if got := groupName(groupKey(east), measure.SfnRecords{east}); got != "mapped" {
+		t.Errorf("groupName() = %q for an ARN group, want the --name-map name", got)
+	}
+}
+
+func TestPerMachineFileNames(t *testing.T) {
+	got := perMachineFileNames([]string{"a:b", "a/b", "ETL", "etl", "plain"})
+	if got["plain"] != "plain" {
+		t.Errorf("got %q for a name without a collision, want it kept", got["plain"])
+	}
+	seen := make(map[string]string)
+	for name, fileName := range got {
+		key := strings.ToLower(fileName)
+		if other, ok := seen[key]; ok {
+			t.Errorf("%q and %q share the file name %q", name, other, fileName)
+		}
+		seen[key] = name
+	}
+	if again := perMachineFileNames([]string{"a/b", "a:b"}); again["a:b"] != got["a:b"] {
+		t.Errorf("got %q and %q for a:b across runs, want a stable file name", got["a:b"], again["a:b"])
+	}
+}
+
+func TestSingleEnvIsTagged(t *testing.T) {
+	defer func(v string) { *envFlag = v }(*envFlag)
+	*envFlag = "prod"
+
+	prod := target{env: "prod", account: "111111111111"}
+	if c := (cycle{targets: []target{prod}}); !c.perTarget() {
+		t.Fatal("a single --env target is collected without tagging its records")
+	}
+	records := measure.SfnRecords{{Name: "a"}, {Name: "b"}}
+	prod.tag(records)
+	for _, r := range records {
+		if r.Env != "prod" || r.Account != "111111111111" {
+			t.Errorf("got Env %q and Account %q, want prod and 111111111111", r.Env, r.Account)
+		}
+	}
+
+	*envFlag = ""
+	if c := (cycle{targets: []target{{}}}); c.perTarget() {
+		t.Error("a single target without --env goes through collectTargets")
+	}
+}
+
+func TestRateLimiter(t *testing.T) {
+	l := &rateLimiter{interval: 20 * time.Millisecond}
+	start := time.Now()
+	for range 3 {
+		if err := l.wait(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 40*time.Millisecond {
+		t.Errorf("3 calls took %s, want at least 2 intervals", elapsed)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := l.wait(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("wait() = %v with a cancelled context, want context.Canceled", err)
+	}
+}
+
+func TestJSONRecordRunID(t *testing.T) {
+	defer func(v string) { runID = v }(runID)
+	columns := []recordColumn{{Header: "Name", Type: columnString, Value: func(r measure.SfnRecord) string { return r.Name }}}
+	record := jsonRecord{record: measure.SfnRecord{Name: "a"}, columns: columns}
+
+	for id, want := range map[string]string{
+		"":   `{"Name":"a"}`,
+		"R1": `{"run_id":"R1","Name":"a"}`,
+	} {
+		runID = id
+		got, err := json.Marshal(record)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != want {
+			t.Errorf("run ID %q: got %s, want %s", id, got, want)
+		}
+	}
+}
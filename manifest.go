@@ -0,0 +1,103 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+)
+
+// runManifest is the manifest.json encoding of --manifest. Flags holds the
+// flags set on the command line.
+type runManifest struct {
+	RunID string            `json:"run_id,omitempty"`
+	Flags map[string]string `json:"flags"`
+	Files []manifestFile    `json:"files"`
+}
+
+// manifestFile is an output file with its path relative to --output-dir,
+// unless it was written elsewhere.
+type manifestFile struct {
+	Name   string `json:"name"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// createManifestFile writes manifest.json listing every file createOutput
+// wrote. It must run after the other outputs are closed so that the sizes
+// and checksums are final.
+func createManifestFile() error {
+	m := runManifest{RunID: runID, Flags: make(map[string]string), Files: []manifestFile{}}
+	flag.Visit(func(f *flag.Flag) {
+		m.Flags[f.Name] = manifestFlagValue(f)
+	})
+
+	path := outputPath("manifest.json")
+	for _, output := range createdOutputs {
+		if output == path {
+			continue
+		}
+		file, err := checksumFile(output)
+		if err != nil {
+			return err
+		}
+		m.Files = append(m.Files, file)
+	}
+
+	w, err := createOutput(path)
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+
+	encoder := json.NewEncoder(w)
+	if !*compact {
+		encoder.SetIndent("", "  ")
+	}
+	if err := encoder.Encode(m); err != nil {
+		return err
+	}
+	return w.Close()
+}
+
+// manifestFlagValue returns the value of f as recorded in manifest.json, with
+// secrets redacted: the --mfa-token and the user info of --otlp-endpoint.
+func manifestFlagValue(f *flag.Flag) string {
+	value := f.Value.String()
+	switch f.Name {
+	case "mfa-token":
+		return redacted
+	case "otlp-endpoint":
+		if u, err := url.Parse(value); err == nil && u.User != nil {
+			u.User = url.User(redacted)
+			return u.String()
+		}
+	}
+	return value
+}
+
+const redacted = "REDACTED"
+
+func checksumFile(path string) (manifestFile, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return manifestFile{}, err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	size, err := io.Copy(h, f)
+	if err != nil {
+		return manifestFile{}, err
+	}
+
+	name := path
+	if rel, err := filepath.Rel(outputDir, path); err == nil && filepath.IsLocal(rel) {
+		name = rel
+	}
+	return manifestFile{Name: filepath.ToSlash(name), Size: size, SHA256: hex.EncodeToString(h.Sum(nil))}, nil
+}
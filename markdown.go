@@ -0,0 +1,109 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/Finatext/measure-sfn/measure"
+)
+
+// renderMarkdown writes the aggregate as a Markdown table sorted by name.
+func renderMarkdown(w io.Writer, aggregated measure.AggregatedRecordMap) error {
+	if err := writeMarkdownRunID(w); err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(w, "| Name | %s | %s | %s | Len |\n| --- | ---: | ---: | ---: | ---: |\n",
+		durationHeader("Max"), durationHeader("Min"), durationHeader("Avg")); err != nil {
+		return err
+	}
+	for _, name := range aggregated.Names() {
+		records := aggregated[name]
+		if _, err := fmt.Fprintf(w, "| %s | %s | %s | %s | %s |\n",
+			escapeMarkdown(displayName(name)),
+			humanNumber(formatDuration(records.MaxDuration())),
+			humanNumber(formatDuration(records.MinDuration())),
+			humanNumber(formatDuration(records.AvgDuration())),
+			humanNumber(strconv.Itoa(records.Len())),
+		); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeMarkdownRunID writes the run ID as a line ahead of a table, when set.
+func writeMarkdownRunID(w io.Writer) error {
+	if runID == "" {
+		return nil
+	}
+	_, err := fmt.Fprintf(w, "Run ID: %s\n\n", escapeMarkdown(runID))
+	return err
+}
+
+func escapeMarkdown(s string) string {
+	return strings.ReplaceAll(s, "|", `\|`)
+}
+
+// writeGitHubSummary appends the Markdown table to the GitHub Actions job
+// summary file, or writes it to stdout when not running in Actions.
+func writeGitHubSummary(aggregated measure.AggregatedRecordMap) error {
+	path := os.Getenv("GITHUB_STEP_SUMMARY")
+	if path == "" {
+		return renderMarkdown(os.Stdout, aggregated)
+	}
+
+	w, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+
+	if err := renderMarkdown(w, aggregated); err != nil {
+		return err
+	}
+	return w.Close()
+}
+
+// createMarkdownFile writes the records to path as a Markdown table, with
+// numeric columns right-aligned.
+func createMarkdownFile(path string, records measure.SfnRecords, columns []recordColumn) error {
+	w, err := createOutput(path)
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+	bw := bufio.NewWriter(w)
+
+	headers := make([]string, len(columns))
+	aligns := make([]string, len(columns))
+	for i, column := range columns {
+		headers[i] = escapeMarkdown(column.Header)
+		aligns[i] = "---"
+		if column.Type != columnString {
+			aligns[i] = "---:"
+		}
+	}
+	writeMarkdownRunID(bw)
+	fmt.Fprintf(bw, "| %s |\n| %s |\n", strings.Join(headers, " | "), strings.Join(aligns, " | "))
+
+	values := make([]string, len(columns))
+	for _, record := range records {
+		for i, column := range columns {
+			value := displayValue(column, record)
+			if column.Header == "Name" {
+				value = displayName(value)
+			}
+			values[i] = escapeMarkdown(value)
+		}
+		fmt.Fprintf(bw, "| %s |\n", strings.Join(values, " | "))
+	}
+
+	if err := bw.Flush(); err != nil {
+		return err
+	}
+	return w.Close()
+}
@@ -0,0 +1,157 @@
+package measure
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+// Accumulator holds running statistics over durations without retaining the
+// records, for callers that cannot keep every record in memory. Percentiles
+// are approximated with a logarithmic histogram, see Percentile.
+type Accumulator struct {
+	Count int
+	Total time.Duration
+	Min   time.Duration
+	Max   time.Duration
+	// mean and m2 are maintained with Welford's algorithm.
+	mean float64
+	m2   float64
+	days map[string]struct{}
+	// buckets counts the positive durations per logarithmic bucket and zeros
+	// the others.
+	buckets map[int]int
+	zeros   int
+}
+
+// AggregateStats are the statistics shared by the exact SfnRecords methods and
+// the running Accumulator, so that callers can switch between them.
+type AggregateStats struct {
+	Count int
+	Total time.Duration
+	Min   time.Duration
+	Max   time.Duration
+	Avg   time.Duration
+	P50   time.Duration
+	P95   time.Duration
+	P99   time.Duration
+}
+
+// accumulatorAccuracy is the relative error of Accumulator.Percentile. Bucket
+// i holds the durations in (gamma^(i-1), gamma^i] nanoseconds; answering with
+// the bucket's harmonic midpoint keeps any duration within the accuracy of
+// it. Durations from a nanosecond to a year span about 1,900 buckets.
+const accumulatorAccuracy = 0.01
+
+var accumulatorGamma = (1 + accumulatorAccuracy) / (1 - accumulatorAccuracy)
+
+// Add includes the record in the statistics.
+func (a *Accumulator) Add(record SfnRecord) {
+	if a.Count == 0 || record.Duration < a.Min {
+		a.Min = record.Duration
+	}
+	if a.Count == 0 || record.Duration > a.Max {
+		a.Max = record.Duration
+	}
+	a.Count++
+	a.Total += record.Duration
+
+	d := float64(record.Duration)
+	delta := d - a.mean
+	a.mean += delta / float64(a.Count)
+	a.m2 += delta * (d - a.mean)
+
+	if a.days == nil {
+		a.days = make(map[string]struct{})
+	}
+	a.days[record.StartDate] = struct{}{}
+
+	if record.Duration <= 0 {
+		a.zeros++
+		return
+	}
+	if a.buckets == nil {
+		a.buckets = make(map[int]int)
+	}
+	a.buckets[int(math.Ceil(math.Log(d)/math.Log(accumulatorGamma)))]++
+}
+
+// Percentile returns the p-th percentile (0-100) of the durations within a
+// relative error of 1%, using the nearest rank rather than interpolating. It
+// returns 0 for no records, and the exact minimum and maximum for p of 0 and
+// 100.
+func (a Accumulator) Percentile(p float64) time.Duration {
+	if a.Count == 0 {
+		return 0
+	}
+	if p <= 0 {
+		return a.Min
+	}
+	if p >= 100 {
+		return a.Max
+	}
+
+	rank := int(math.Round(p / 100 * float64(a.Count-1)))
+	if rank < a.zeros {
+		return 0
+	}
+	rank -= a.zeros
+
+	indexes := make([]int, 0, len(a.buckets))
+	for i := range a.buckets {
+		indexes = append(indexes, i)
+	}
+	sort.Ints(indexes)
+	for _, i := range indexes {
+		if rank < a.buckets[i] {
+			estimate := time.Duration(2 * math.Pow(accumulatorGamma, float64(i)) / (accumulatorGamma + 1))
+			return min(max(estimate, a.Min), a.Max)
+		}
+		rank -= a.buckets[i]
+	}
+	return a.Max
+}
+
+func (a Accumulator) AvgDuration() time.Duration {
+	if a.Count == 0 {
+		return 0
+	}
+	return a.Total / time.Duration(a.Count)
+}
+
+// StdDev returns the population standard deviation of the durations.
+func (a Accumulator) StdDev() time.Duration {
+	if a.Count == 0 {
+		return 0
+	}
+	return time.Duration(math.Sqrt(a.m2 / float64(a.Count)))
+}
+
+// CoefficientOfVariation returns the standard deviation relative to the mean,
+// or 0 when the mean is zero.
+func (a Accumulator) CoefficientOfVariation() float64 {
+	if a.mean == 0 {
+		return 0
+	}
+	return float64(a.StdDev()) / a.mean
+}
+
+// ActiveDays returns the number of distinct start dates.
+func (a Accumulator) ActiveDays() int {
+	return len(a.days)
+}
+
+// Result returns the statistics so far, with the percentiles approximated as
+// in Percentile.
+func (a Accumulator) Result() AggregateStats {
+	return AggregateStats{
+		Count: a.Count,
+		Total: a.Total,
+		Min:   a.Min,
+		Max:   a.Max,
+		Avg:   a.AvgDuration(),
+		P50:   a.Percentile(50),
+		P95:   a.Percentile(95),
+		P99:   a.Percentile(99),
+	}
+}
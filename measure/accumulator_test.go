@@ -0,0 +1,80 @@
+package measure
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+// accumulatorRecords is a fixed dataset with a long tail, a zero and a
+// duplicate, spread over three days.
+func accumulatorRecords() SfnRecords {
+	durations := []time.Duration{
+		0, 120 * time.Millisecond, 450 * time.Millisecond, time.Second, time.Second,
+		1500 * time.Millisecond, 2 * time.Second, 3 * time.Second, 5 * time.Second,
+		8 * time.Second, 13 * time.Second, 21 * time.Second, 34 * time.Second,
+		55 * time.Second, 89 * time.Second, 144 * time.Second, 10 * time.Minute,
+	}
+	days := []string{"2024-01-01", "2024-01-02", "2024-01-03"}
+	records := make(SfnRecords, len(durations))
+	for i, d := range durations {
+		records[i] = SfnRecord{Name: "machine", StartDate: days[i%len(days)], Duration: d}
+	}
+	return records
+}
+
+func TestAccumulatorMatchesExactStats(t *testing.T) {
+	records := accumulatorRecords()
+	var acc Accumulator
+	for _, record := range records {
+		acc.Add(record)
+	}
+
+	got, want := acc.Result(), records.Stats()
+	if got.Count != want.Count || got.Total != want.Total || got.Min != want.Min || got.Max != want.Max || got.Avg != want.Avg {
+		t.Errorf("Result() = %+v, want the exact count, total, min, max and avg of %+v", got, want)
+	}
+	if got, want := acc.ActiveDays(), records.ActiveDays(); got != want {
+		t.Errorf("ActiveDays() = %d, want %d", got, want)
+	}
+	if got, want := acc.StdDev(), records.StdDev(); absDuration(got-want) > time.Microsecond {
+		t.Errorf("StdDev() = %s, want %s", got, want)
+	}
+	if got, want := acc.CoefficientOfVariation(), records.CoefficientOfVariation(); math.Abs(got-want) > 1e-9 {
+		t.Errorf("CoefficientOfVariation() = %f, want %f", got, want)
+	}
+}
+
+func TestAccumulatorPercentileWithinAccuracy(t *testing.T) {
+	records := accumulatorRecords()
+	var acc Accumulator
+	for _, record := range records {
+		acc.Add(record)
+	}
+
+	// The accumulator answers with the nearest rank, so compare it with the
+	// exact duration of that rank rather than the interpolated percentile.
+	sorted := records.Slowest(records.Len())
+	for _, p := range []float64{0, 10, 25, 50, 75, 90, 95, 99, 100} {
+		rank := int(math.Round(p / 100 * float64(len(sorted)-1)))
+		want := sorted[len(sorted)-1-rank].Duration
+		got := acc.Percentile(p)
+		if math.Abs(float64(got-want)) > accumulatorAccuracy*float64(want) {
+			t.Errorf("Percentile(%v) = %s, want %s within %v", p, got, want, accumulatorAccuracy)
+		}
+	}
+}
+
+func TestAccumulatorEmpty(t *testing.T) {
+	var acc Accumulator
+	if got, want := acc.Result(), (SfnRecords{}).Stats(); got != want {
+		t.Errorf("Result() = %+v, want %+v", got, want)
+	}
+}
+
+func absDuration(d time.Duration) time.Duration {
+	if d < 0 {
+		return -d
+	}
+	return d
+}
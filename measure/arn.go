@@ -0,0 +1,72 @@
+package measure
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws/arn"
+)
+
+// StateMachineName extracts the state machine name from a state machine ARN in
+// any partition, e.g. arn:aws-us-gov:states:us-gov-west-1:123456789012:stateMachine:Name.
+func StateMachineName(machineArn string) (string, error) {
+	a, err := arn.Parse(machineArn)
+	if err != nil {
+		return "", err
+	}
+
+	resource := strings.Split(a.Resource, ":")
+	if a.Service != "states" || len(resource) < 2 || resource[0] != "stateMachine" {
+		return "", fmt.Errorf("not a state machine ARN: %s", machineArn)
+	}
+	return resource[1], nil
+}
+
+// Name styles of Options.NameStyle.
+const (
+	// NameStyleName is the bare state machine name, e.g. Name.
+	NameStyleName = "name"
+	// NameStyleNameVersion qualifies the name with the version the execution
+	// ran, as in a version ARN, e.g. Name:3. Unversioned executions keep the
+	// bare name.
+	NameStyleNameVersion = "name-version"
+	// NameStyleARN is the full state machine ARN.
+	NameStyleARN = "arn"
+)
+
+// DisplayName formats the name of a state machine in the given style. version
+// is only used by NameStyleNameVersion. An empty or unknown style, like an
+// ARN that cannot be parsed, yields the bare name when there is one.
+func DisplayName(machineArn, version, style string) string {
+	if style == NameStyleARN {
+		return machineArn
+	}
+	name, err := StateMachineName(machineArn)
+	if err != nil {
+		return machineArn
+	}
+	if style == NameStyleNameVersion && version != "" {
+		return name + ":" + version
+	}
+	return name
+}
+
+// Region returns the region of an ARN, or "" when it cannot be parsed.
+func Region(resourceArn string) string {
+	a, err := arn.Parse(resourceArn)
+	if err != nil {
+		return ""
+	}
+	return a.Region
+}
+
+// Qualifier returns the version number or alias name qualifying a state
+// machine ARN such as arn:aws:states:us-east-1:123456789012:stateMachine:Name:3,
+// or "" for an unqualified ARN.
+func Qualifier(machineArn string) string {
+	parts := strings.Split(machineArn, ":")
+	if len(parts) < 8 {
+		return ""
+	}
+	return parts[7]
+}
@@ -0,0 +1,33 @@
+package measure
+
+import "testing"
+
+func TestStateMachineName(t *testing.T) {
+	tests := []struct {
+		arn     string
+		want    string
+		wantErr bool
+	}{
+		{arn: "arn:aws:states:us-east-1:123456789012:stateMachine:Orders", want: "Orders"},
+		{arn: "arn:aws-cn:states:cn-north-1:123456789012:stateMachine:Orders", want: "Orders"},
+		{arn: "arn:aws-us-gov:states:us-gov-west-1:123456789012:stateMachine:Orders", want: "Orders"},
+		// Version and alias ARNs qualify the state machine ARN.
+		{arn: "arn:aws:states:us-east-1:123456789012:stateMachine:Orders:3", want: "Orders"},
+		{arn: "arn:aws-cn:states:cn-northwest-1:123456789012:stateMachine:Orders:live", want: "Orders"},
+		{arn: "arn:aws-us-gov:states:us-gov-east-1:123456789012:execution:Orders:run-1", wantErr: true},
+		{arn: "arn:aws:lambda:us-east-1:123456789012:function:Orders", wantErr: true},
+		{arn: "Orders", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := StateMachineName(tt.arn)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("StateMachineName(%q) = %q, want an error", tt.arn, got)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("StateMachineName(%q) = %q, %v, want %q", tt.arn, got, err, tt.want)
+		}
+	}
+}
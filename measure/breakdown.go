@@ -0,0 +1,91 @@
+package measure
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sfn"
+)
+
+// StateStats is the time spent in one state across executions.
+type StateStats struct {
+	Name string
+	// Executions is the number of executions that entered the state and
+	// Entries the number of times it was entered, which is larger for loops
+	// and Map iterations.
+	Executions int
+	Entries    int
+	Total      time.Duration
+}
+
+// AvgPerExecution returns the average time an execution entering the state
+// spent in it.
+func (s StateStats) AvgPerExecution() time.Duration {
+	if s.Executions == 0 {
+		return 0
+	}
+	return s.Total / time.Duration(s.Executions)
+}
+
+// StateBreakdown walks the history of every record's execution and sums the
+// time between each StateEntered event and the matching StateExited event.
+// Entries of the same state are matched first in, first out, which is exact
+// for sequential states and an approximation inside parallel Map iterations.
+// The result is sorted by total time, longest first.
+func StateBreakdown(ctx context.Context, client Client, records SfnRecords) ([]StateStats, error) {
+	stats := make(map[string]*StateStats)
+
+	for _, record := range records {
+		entered := make(map[string][]time.Time)
+		seen := make(map[string]bool)
+
+		err := client.GetExecutionHistoryPagesWithContext(ctx, &sfn.GetExecutionHistoryInput{
+			ExecutionArn: aws.String(record.ExecutionArn),
+		}, func(page *sfn.GetExecutionHistoryOutput, _ bool) bool {
+			for _, event := range page.Events {
+				switch {
+				case event.StateEnteredEventDetails != nil:
+					name := aws.StringValue(event.StateEnteredEventDetails.Name)
+					entered[name] = append(entered[name], aws.TimeValue(event.Timestamp))
+				case event.StateExitedEventDetails != nil:
+					name := aws.StringValue(event.StateExitedEventDetails.Name)
+					if len(entered[name]) == 0 {
+						continue
+					}
+					start := entered[name][0]
+					entered[name] = entered[name][1:]
+
+					s, ok := stats[name]
+					if !ok {
+						s = &StateStats{Name: name}
+						stats[name] = s
+					}
+					if !seen[name] {
+						seen[name] = true
+						s.Executions++
+					}
+					s.Entries++
+					s.Total += aws.TimeValue(event.Timestamp).Sub(start)
+				}
+			}
+			return ctx.Err() == nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	result := make([]StateStats, 0, len(stats))
+	for _, s := range stats {
+		result = append(result, *s)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Total != result[j].Total {
+			return result[i].Total > result[j].Total
+		}
+		return result[i].Name < result[j].Name
+	})
+	return result, nil
+}
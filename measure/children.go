@@ -0,0 +1,90 @@
+package measure
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sfn"
+)
+
+// LinkChildren reads the history of every record, with at most concurrency
+// executions in flight, and links the child executions started by its
+// startExecution Task states: ChildExecutions counts them on the parent, and
+// the records of the children get ParentName and ParentExecutionArn. Children
+// not among records, e.g. started before the window, are only counted. The
+// returned errors are aligned with records; a failed execution has no
+// children.
+func LinkChildren(ctx context.Context, client Client, records SfnRecords, concurrency int) []error {
+	l := newLimiter(concurrency)
+	errs := make([]error, len(records))
+	children := make([][]string, len(records))
+	var wg sync.WaitGroup
+	for i := range records {
+		if err := l.acquire(ctx); err != nil {
+			for j := i; j < len(errs); j++ {
+				errs[j] = err
+			}
+			break
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer l.release()
+			children[i], errs[i] = childExecutions(ctx, client, records[i].ExecutionArn)
+		}()
+	}
+	wg.Wait()
+
+	index := make(map[string]int, len(records))
+	for i, record := range records {
+		index[record.ExecutionArn] = i
+	}
+	for i, arns := range children {
+		records[i].ChildExecutions = len(arns)
+		for _, arn := range arns {
+			if j, ok := index[arn]; ok {
+				records[j].ParentName = records[i].Name
+				records[j].ParentExecutionArn = records[i].ExecutionArn
+			}
+		}
+	}
+	return errs
+}
+
+// childExecutions returns the ARNs of the executions started by the
+// startExecution Task states of an execution, in the order they were
+// started. The ARN is in the output of the TaskSubmitted event for the .sync
+// integrations and of the TaskSucceeded event for the request-response one.
+func childExecutions(ctx context.Context, client Client, executionArn string) ([]string, error) {
+	var arns []string
+	seen := make(map[string]bool)
+	add := func(resourceType, resource, output *string) {
+		if aws.StringValue(resourceType) != "states" || !strings.HasPrefix(aws.StringValue(resource), "startExecution") {
+			return
+		}
+		var started struct{ ExecutionArn string }
+		if json.Unmarshal([]byte(aws.StringValue(output)), &started) != nil || started.ExecutionArn == "" || seen[started.ExecutionArn] {
+			return
+		}
+		seen[started.ExecutionArn] = true
+		arns = append(arns, started.ExecutionArn)
+	}
+
+	err := client.GetExecutionHistoryPagesWithContext(ctx, &sfn.GetExecutionHistoryInput{
+		ExecutionArn: aws.String(executionArn),
+	}, func(page *sfn.GetExecutionHistoryOutput, _ bool) bool {
+		for _, event := range page.Events {
+			if d := event.TaskSubmittedEventDetails; d != nil {
+				add(d.ResourceType, d.Resource, d.Output)
+			}
+			if d := event.TaskSucceededEventDetails; d != nil {
+				add(d.ResourceType, d.Resource, d.Output)
+			}
+		}
+		return ctx.Err() == nil
+	})
+	return arns, err
+}
@@ -0,0 +1,138 @@
+package measure_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Finatext/measure-sfn/measure"
+	"github.com/Finatext/measure-sfn/measure/measuretest"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/sfn"
+)
+
+func TestCollect(t *testing.T) {
+	client := measuretest.New(3, 250, func(i, j int) time.Duration {
+		return time.Duration(i+1) * time.Second
+	}, func(i, j int) string {
+		if j%10 == 0 {
+			return sfn.ExecutionStatusFailed
+		}
+		return sfn.ExecutionStatusSucceeded
+	})
+
+	listed := -1
+	result, err := measure.Collect(context.Background(), client, measure.Options{
+		Since:       time.Now().AddDate(0, -2, 0),
+		Concurrency: 4,
+		Listed:      func(machines int) { listed = machines },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if listed != 3 {
+		t.Errorf("Listed got %d state machines, want 3", listed)
+	}
+	if got, want := result.Records.Len(), 3*250; got != want {
+		t.Fatalf("got %d records, want %d", got, want)
+	}
+
+	aggregated := result.Records.Aggregate()
+	for i, name := range []string{"machine-0", "machine-1", "machine-2"} {
+		records := aggregated[name]
+		if got, want := records.AvgDuration(), time.Duration(i+1)*time.Second; got != want {
+			t.Errorf("%s: AvgDuration() = %s, want %s", name, got, want)
+		}
+		if got, want := records.GroupByStatus()[sfn.ExecutionStatusFailed].Len(), 25; got != want {
+			t.Errorf("%s: got %d failed executions, want %d", name, got, want)
+		}
+	}
+}
+
+func TestCollectThrottled(t *testing.T) {
+	client := measuretest.New(2, 10, nil, nil)
+	client.ThrottleEvery = 2
+
+	_, err := measure.Collect(context.Background(), client, measure.Options{Since: time.Now().AddDate(0, -2, 0)})
+	var aerr awserr.Error
+	if !errors.As(err, &aerr) || aerr.Code() != "ThrottlingException" {
+		t.Fatalf("Collect() error = %v, want a ThrottlingException", err)
+	}
+}
+
+func TestCollectNegativeDuration(t *testing.T) {
+	client := measuretest.New(1, 4, func(i, j int) time.Duration {
+		if j%2 == 0 {
+			return -time.Minute
+		}
+		return time.Minute
+	}, nil)
+	since := time.Now().AddDate(0, -2, 0)
+
+	var (
+		mu       sync.Mutex
+		warnings []string
+	)
+	result, err := measure.Collect(context.Background(), client, measure.Options{
+		Since: since,
+		Warnf: func(format string, args ...any) {
+			mu.Lock()
+			defer mu.Unlock()
+			warnings = append(warnings, fmt.Sprintf(format, args...))
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := result.Records.Len(), 2; got != want {
+		t.Fatalf("got %d records, want %d", got, want)
+	}
+	if got := result.Records.MinDuration(); got != time.Minute {
+		t.Errorf("MinDuration() = %s, want %s", got, time.Minute)
+	}
+	if len(warnings) != 2 {
+		t.Fatalf("got warnings %q, want 2", warnings)
+	}
+	for _, w := range warnings {
+		if !strings.Contains(w, "stop date is before start date") {
+			t.Errorf("unexpected warning %q", w)
+		}
+	}
+
+	result, err = measure.Collect(context.Background(), client, measure.Options{Since: since, KeepNegative: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := result.Records.Len(), 4; got != want {
+		t.Fatalf("KeepNegative: got %d records, want %d", got, want)
+	}
+	if got := result.Records.MinDuration(); got != -time.Minute {
+		t.Errorf("KeepNegative: MinDuration() = %s, want %s", got, -time.Minute)
+	}
+}
+
+// BenchmarkCollectDescribe compares the serial describe calls with the
+// concurrent ones on a single busy state machine, where only the calls
+// within the machine can overlap.
+func BenchmarkCollectDescribe(b *testing.B) {
+	for _, concurrency := range []int{1, 4, 16} {
+		b.Run(fmt.Sprintf("concurrency=%d", concurrency), func(b *testing.B) {
+			client := measuretest.New(1, 1000, nil, nil)
+			client.Latency = 50 * time.Microsecond
+			opts := measure.Options{
+				Since:       time.Now().AddDate(0, -2, 0),
+				Describe:    true,
+				Concurrency: concurrency,
+			}
+			for range b.N {
+				if _, err := measure.Collect(context.Background(), client, opts); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,122 @@
+package measure
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sfn"
+)
+
+// MachineCount is the number of executions of one state machine per status.
+type MachineCount struct {
+	Name            string
+	StateMachineArn string
+	Statuses        map[string]int
+}
+
+// Total returns the number of executions across the statuses.
+func (m MachineCount) Total() int {
+	total := 0
+	for _, n := range m.Statuses {
+		total += n
+	}
+	return total
+}
+
+// Count pages through the executions of every state machine like Collect but
+// only counts them, without building records. The machine, status, name
+// prefix and Since filters apply; running executions are counted as well,
+// except with FilterByStop. Describe, Version, Alias and MaxRunning are
+// ignored. Counts keep the order of the state machines as listed.
+func Count(ctx context.Context, client Client, opts Options) ([]MachineCount, error) {
+	machines, err := listStateMachines(ctx, client, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &collector{
+		client:  client,
+		opts:    opts,
+		limiter: newLimiter(opts.Concurrency),
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	counts := make([]MachineCount, len(machines))
+	wanted := make([]bool, len(machines))
+	errs := make([]error, len(machines))
+	var wg sync.WaitGroup
+	for i, machine := range machines {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			counts[i], wanted[i], errs[i] = c.countMachine(ctx, machine)
+			if errs[i] != nil {
+				cancel()
+			}
+		}()
+	}
+	wg.Wait()
+
+	var result []MachineCount
+	var firstErr error
+	for i, count := range counts {
+		if wanted[i] {
+			result = append(result, count)
+		}
+		if firstErr == nil && errs[i] != nil && !errors.Is(errs[i], context.Canceled) {
+			firstErr = errs[i]
+		}
+	}
+	if firstErr != nil {
+		return result, firstErr
+	}
+	return result, ctx.Err()
+}
+
+// countMachine counts the executions of a state machine, reporting false for
+// a state machine left out by the Include and Exclude filters.
+func (c *collector) countMachine(ctx context.Context, machine *sfn.StateMachineListItem) (MachineCount, bool, error) {
+	name, err := StateMachineName(*machine.StateMachineArn)
+	if err != nil {
+		return MachineCount{}, false, err
+	}
+	if !c.opts.wantsMachine(name) {
+		return MachineCount{}, false, nil
+	}
+
+	count := MachineCount{
+		Name:            name,
+		StateMachineArn: aws.StringValue(machine.StateMachineArn),
+		Statuses:        make(map[string]int),
+	}
+
+	input := &sfn.ListExecutionsInput{}
+	if c.opts.ListExecutionsInput != nil {
+		*input = *c.opts.ListExecutionsInput
+	}
+	input.StateMachineArn = machine.StateMachineArn
+	for {
+		if err := c.limiter.acquire(ctx); err != nil {
+			return count, true, err
+		}
+		page, err := c.client.ListExecutionsWithContext(ctx, input)
+		c.limiter.release()
+		if err != nil {
+			return count, true, err
+		}
+
+		for _, execution := range page.Executions {
+			if c.matchesExecution(execution) {
+				count.Statuses[aws.StringValue(execution.Status)]++
+			}
+		}
+
+		if page.NextToken == nil {
+			return count, true, ctx.Err()
+		}
+		input.NextToken = page.NextToken
+	}
+}
@@ -0,0 +1,168 @@
+package measure
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// durationColumns maps the duration header of each --unit to the unit.
+var durationColumns = map[string]time.Duration{
+	"Duration":    time.Second,
+	"DurationMs":  time.Millisecond,
+	"DurationMin": time.Minute,
+	"DurationH":   time.Hour,
+}
+
+// ReadCSV reads back records previously written to sfn.csv by measure-sfn,
+// in any --unit or --duration-format and with any of the optional columns.
+// Lines starting with #, such as the run ID, are skipped. Columns that are not
+// written to the file, e.g. the execution ARN, stay empty, and StartTime falls
+// back to midnight UTC of StartDate for files predating the StartTimestamp
+// column.
+func ReadCSV(r io.Reader) (SfnRecords, error) {
+	reader := csv.NewReader(r)
+	reader.Comment = '#'
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("reading header: %w", err)
+	}
+	index := make(map[string]int, len(header))
+	for i, name := range header {
+		index[name] = i
+	}
+
+	var unit time.Duration
+	durationIndex := -1
+	for name, u := range durationColumns {
+		if i, ok := index[name]; ok {
+			unit, durationIndex = u, i
+		}
+	}
+	if _, ok := index["Name"]; !ok || durationIndex < 0 {
+		return nil, errors.New("not a records file: the Name and Duration columns are required")
+	}
+
+	records := SfnRecords{}
+	for line := 2; ; line++ {
+		row, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return records, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		field := func(name string) string {
+			if i, ok := index[name]; ok {
+				return row[i]
+			}
+			return ""
+		}
+		record, err := parseRecord(field, row[durationIndex], unit)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		records = append(records, record)
+	}
+}
+
+// parseRecord builds a record from the columns of a records file. field
+// returns the value of a column, or "" when the file has no such column, and
+// duration is the value of the duration column, in unit.
+func parseRecord(field func(name string) string, duration string, unit time.Duration) (SfnRecord, error) {
+	d, err := parseDuration(duration, unit)
+	if err != nil {
+		return SfnRecord{}, err
+	}
+	record := SfnRecord{
+		Name:      field("Name"),
+		StartDate: field("StartDate"),
+		Duration:  d,
+		Status:    field("Status"),
+		Version:   field("Version"),
+		// StateMachineArn is only written with --with-arn.
+		StateMachineArn: field("StateMachineArn"),
+	}
+
+	if s := field("StartTimestamp"); s != "" {
+		if record.StartTime, err = time.Parse(time.RFC3339Nano, s); err != nil {
+			return SfnRecord{}, fmt.Errorf("invalid StartTimestamp: %w", err)
+		}
+		// StartDate may have been written with another layout; the date in
+		// the zone of the timestamp is the one it was measured with.
+		record.StartDate = record.StartTime.Format(time.DateOnly)
+	} else if record.StartDate != "" {
+		if record.StartTime, err = time.Parse(time.DateOnly, record.StartDate); err != nil {
+			return SfnRecord{}, fmt.Errorf("invalid StartDate: %w", err)
+		}
+	}
+
+	for name, dst := range map[string]*int64{"InputBytes": &record.InputBytes, "OutputBytes": &record.OutputBytes} {
+		if s := field(name); s != "" {
+			if *dst, err = strconv.ParseInt(s, 10, 64); err != nil {
+				return SfnRecord{}, fmt.Errorf("invalid %s: %w", name, err)
+			}
+		}
+	}
+	return record, nil
+}
+
+// parseDuration parses a duration column in any --duration-format: a number
+// in unit, an ISO 8601 duration such as PT1M33.5S, or a Go duration such as
+// 1m33.5s.
+func parseDuration(s string, unit time.Duration) (time.Duration, error) {
+	if value, err := strconv.ParseFloat(s, 64); err == nil {
+		return time.Duration(math.Round(value * float64(unit))), nil
+	}
+	if strings.HasPrefix(strings.TrimPrefix(s, "-"), "PT") {
+		return parseISO8601Duration(s)
+	}
+	if d, err := time.ParseDuration(s); err == nil {
+		return d, nil
+	}
+	return 0, fmt.Errorf("invalid duration %q: want a number, an ISO 8601 duration or a Go duration", s)
+}
+
+// parseISO8601Duration parses the time part of an ISO 8601 duration, with
+// hours, minutes and seconds in that order, each optional and the last one
+// possibly fractional, and an optional leading minus. Dates are rejected as
+// their length depends on the calendar.
+func parseISO8601Duration(s string) (time.Duration, error) {
+	invalid := fmt.Errorf("invalid ISO 8601 duration %q", s)
+
+	rest, negative := strings.CutPrefix(s, "-")
+	rest, ok := strings.CutPrefix(rest, "PT")
+	if !ok || rest == "" {
+		return 0, invalid
+	}
+
+	var total time.Duration
+	for _, designator := range []struct {
+		suffix byte
+		unit   time.Duration
+	}{{'H', time.Hour}, {'M', time.Minute}, {'S', time.Second}} {
+		i := strings.IndexByte(rest, designator.suffix)
+		if i < 0 {
+			continue
+		}
+		value, err := strconv.ParseFloat(rest[:i], 64)
+		if err != nil || value < 0 || strings.ContainsAny(rest[:i], "+-eE") {
+			return 0, invalid
+		}
+		total += time.Duration(math.Round(value * float64(designator.unit)))
+		rest = rest[i+1:]
+	}
+	if rest != "" {
+		return 0, invalid
+	}
+	if negative {
+		total = -total
+	}
+	return total, nil
+}
@@ -0,0 +1,37 @@
+package measure
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseDuration(t *testing.T) {
+	tests := []struct {
+		in   string
+		unit time.Duration
+		want time.Duration
+	}{
+		{"1.50", time.Second, 1500 * time.Millisecond},
+		{"250.00", time.Millisecond, 250 * time.Millisecond},
+		{"PT0S", time.Second, 0},
+		{"PT1M33.5S", time.Second, 93500 * time.Millisecond},
+		{"PT3H5S", time.Second, 3*time.Hour + 5*time.Second},
+		{"PT26H", time.Second, 26 * time.Hour},
+		{"-PT1M1S", time.Second, -61 * time.Second},
+		{"PT0.1S", time.Second, 100 * time.Millisecond},
+		{"1m33.5s", time.Second, 93500 * time.Millisecond},
+		{"-2h0m0s", time.Second, -2 * time.Hour},
+	}
+	for _, tt := range tests {
+		got, err := parseDuration(tt.in, tt.unit)
+		if err != nil || got != tt.want {
+			t.Errorf("parseDuration(%q) = %s, %v, want %s", tt.in, got, err, tt.want)
+		}
+	}
+
+	for _, in := range []string{"", "PT", "P1D", "PT5S3M", "PT1H2H", "PT-1S", "1 minute"} {
+		if got, err := parseDuration(in, time.Second); err == nil {
+			t.Errorf("parseDuration(%q) = %s, want an error", in, got)
+		}
+	}
+}
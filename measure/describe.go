@@ -0,0 +1,82 @@
+package measure
+
+import (
+	"context"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sfn"
+)
+
+// limiter bounds the number of API calls in flight.
+type limiter chan struct{}
+
+func newLimiter(concurrency int) limiter {
+	return make(limiter, max(concurrency, 1))
+}
+
+func (l limiter) acquire(ctx context.Context) error {
+	select {
+	case l <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (l limiter) release() {
+	<-l
+}
+
+// DescribeResult is the outcome of the DescribeExecution call of one execution.
+type DescribeResult struct {
+	Output *sfn.DescribeExecutionOutput
+	Err    error
+}
+
+// DescribeExecutions calls DescribeExecution for every execution ARN with at
+// most concurrency calls in flight and returns the results in the order of
+// executionArns. A failed call only fails its own result; once ctx is done
+// the remaining results carry its error. Rate limiting configured on the
+// client applies to each call as usual.
+func DescribeExecutions(ctx context.Context, client Client, executionArns []string, concurrency int) []DescribeResult {
+	return newLimiter(concurrency).describeExecutions(ctx, client, executionArns)
+}
+
+func (l limiter) describeExecutions(ctx context.Context, client Client, executionArns []string) []DescribeResult {
+	results := make([]DescribeResult, len(executionArns))
+	var wg sync.WaitGroup
+	for i, arn := range executionArns {
+		if err := l.acquire(ctx); err != nil {
+			for j := i; j < len(results); j++ {
+				results[j].Err = err
+			}
+			break
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer l.release()
+			results[i].Output, results[i].Err = client.DescribeExecutionWithContext(ctx, &sfn.DescribeExecutionInput{ExecutionArn: aws.String(arn)})
+		}()
+	}
+	wg.Wait()
+	return results
+}
+
+// applyDescribe fills the record fields only available from DescribeExecution:
+// the byte length of the input and output, recorded as 0 when not included in
+// the response, the version and alias the execution ran against, and the
+// failure reason.
+func applyDescribe(record *SfnRecord, out *sfn.DescribeExecutionOutput) {
+	if out.InputDetails == nil || aws.BoolValue(out.InputDetails.Included) {
+		record.InputBytes = int64(len(aws.StringValue(out.Input)))
+	}
+	if out.OutputDetails == nil || aws.BoolValue(out.OutputDetails.Included) {
+		record.OutputBytes = int64(len(aws.StringValue(out.Output)))
+	}
+	record.Version = Qualifier(aws.StringValue(out.StateMachineVersionArn))
+	record.Alias = Qualifier(aws.StringValue(out.StateMachineAliasArn))
+	record.Error = aws.StringValue(out.Error)
+	record.Cause = aws.StringValue(out.Cause)
+}
@@ -0,0 +1,81 @@
+package measure
+
+import (
+	"context"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sfn"
+)
+
+// DescribeRecord returns the record of a single execution from one
+// DescribeExecution call, with the start time in loc. The duration of a
+// running execution is the time elapsed until now.
+func DescribeRecord(ctx context.Context, client Client, executionArn string, loc *time.Location, now time.Time) (SfnRecord, error) {
+	out, err := client.DescribeExecutionWithContext(ctx, &sfn.DescribeExecutionInput{ExecutionArn: aws.String(executionArn)})
+	if err != nil {
+		return SfnRecord{}, err
+	}
+
+	machineArn := aws.StringValue(out.StateMachineArn)
+	name, err := StateMachineName(machineArn)
+	if err != nil {
+		return SfnRecord{}, err
+	}
+
+	stop := now
+	if out.StopDate != nil {
+		stop = *out.StopDate
+	}
+	startTime := aws.TimeValue(out.StartDate).In(loc)
+	record := SfnRecord{
+		Name:            name,
+		StateMachineArn: machineArn,
+		Region:          Region(machineArn),
+		ExecutionName:   aws.StringValue(out.Name),
+		ExecutionArn:    aws.StringValue(out.ExecutionArn),
+		StartDate:       startTime.Format(time.DateOnly),
+		StartTime:       startTime,
+		Duration:        stop.Sub(aws.TimeValue(out.StartDate)),
+		Status:          aws.StringValue(out.Status),
+	}
+	applyDescribe(&record, out)
+	return record, nil
+}
+
+// FailedState returns the state the execution was in when it failed, i.e. the
+// last state entered according to its history, or "" when it entered none.
+func FailedState(ctx context.Context, client Client, executionArn string) (string, error) {
+	var state string
+	err := client.GetExecutionHistoryPagesWithContext(ctx, &sfn.GetExecutionHistoryInput{
+		ExecutionArn: aws.String(executionArn),
+		ReverseOrder: aws.Bool(true),
+	}, func(page *sfn.GetExecutionHistoryOutput, _ bool) bool {
+		for _, event := range page.Events {
+			if event.StateEnteredEventDetails != nil {
+				state = aws.StringValue(event.StateEnteredEventDetails.Name)
+				return false
+			}
+		}
+		return ctx.Err() == nil
+	})
+	return state, err
+}
+
+// EnteredState reports whether the history of the execution has a
+// StateEntered event for the named state, reading no further than the event.
+func EnteredState(ctx context.Context, client Client, executionArn, state string) (bool, error) {
+	entered := false
+	err := client.GetExecutionHistoryPagesWithContext(ctx, &sfn.GetExecutionHistoryInput{
+		ExecutionArn: aws.String(executionArn),
+	}, func(page *sfn.GetExecutionHistoryOutput, _ bool) bool {
+		for _, event := range page.Events {
+			if event.StateEnteredEventDetails != nil && aws.StringValue(event.StateEnteredEventDetails.Name) == state {
+				entered = true
+				return false
+			}
+		}
+		return ctx.Err() == nil
+	})
+	return entered, err
+}
@@ -0,0 +1,44 @@
+package measure
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sfn"
+)
+
+// Failed reports whether the execution ended without succeeding.
+func (r SfnRecord) Failed() bool {
+	switch r.Status {
+	case sfn.ExecutionStatusFailed, sfn.ExecutionStatusTimedOut, sfn.ExecutionStatusAborted:
+		return true
+	}
+	return false
+}
+
+// DescribeFailures fills Error and Cause of every failed record with one
+// DescribeExecution call each, with at most concurrency calls in flight.
+// The returned errors are aligned with records; a failed call leaves the
+// record unchanged.
+func DescribeFailures(ctx context.Context, client Client, records SfnRecords, concurrency int) []error {
+	var indexes []int
+	var arns []string
+	for i, record := range records {
+		if record.Failed() {
+			indexes = append(indexes, i)
+			arns = append(arns, record.ExecutionArn)
+		}
+	}
+
+	errs := make([]error, len(records))
+	for k, r := range DescribeExecutions(ctx, client, arns, concurrency) {
+		i := indexes[k]
+		if r.Err != nil {
+			errs[i] = r.Err
+			continue
+		}
+		records[i].Error = aws.StringValue(r.Output.Error)
+		records[i].Cause = aws.StringValue(r.Output.Cause)
+	}
+	return errs
+}
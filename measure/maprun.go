@@ -0,0 +1,65 @@
+package measure
+
+import (
+	"context"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sfn"
+)
+
+// CountMapItems fills MapRuns and MapItems of every record with the
+// Distributed Map runs its execution started and the items they processed,
+// with at most concurrency executions in flight. Each execution costs a
+// ListMapRuns call plus a DescribeMapRun call per map run. The returned
+// errors are aligned with records; a failed execution keeps zero counts.
+func CountMapItems(ctx context.Context, client Client, records SfnRecords, concurrency int) []error {
+	l := newLimiter(concurrency)
+	errs := make([]error, len(records))
+	var wg sync.WaitGroup
+	for i := range records {
+		if err := l.acquire(ctx); err != nil {
+			for j := i; j < len(errs); j++ {
+				errs[j] = err
+			}
+			break
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer l.release()
+			errs[i] = countMapItems(ctx, client, &records[i])
+		}()
+	}
+	wg.Wait()
+	return errs
+}
+
+func countMapItems(ctx context.Context, client Client, record *SfnRecord) error {
+	var mapRunArns []*string
+	err := client.ListMapRunsPagesWithContext(ctx, &sfn.ListMapRunsInput{
+		ExecutionArn: aws.String(record.ExecutionArn),
+	}, func(page *sfn.ListMapRunsOutput, _ bool) bool {
+		for _, run := range page.MapRuns {
+			mapRunArns = append(mapRunArns, run.MapRunArn)
+		}
+		return ctx.Err() == nil
+	})
+	if err != nil {
+		return err
+	}
+
+	var items int64
+	for _, arn := range mapRunArns {
+		out, err := client.DescribeMapRunWithContext(ctx, &sfn.DescribeMapRunInput{MapRunArn: arn})
+		if err != nil {
+			return err
+		}
+		if out.ItemCounts != nil {
+			items += aws.Int64Value(out.ItemCounts.Total)
+		}
+	}
+	record.MapRuns = len(mapRunArns)
+	record.MapItems = items
+	return nil
+}
@@ -0,0 +1,618 @@
+// Package measure collects Step Functions execution durations and computes
+// statistics over them. It never writes files or exits the process; the
+// measure-sfn command is a thin wrapper around it.
+package measure
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/sfn"
+)
+
+// Client is the subset of the SFN API used by Collect. *sfn.SFN implements it.
+type Client interface {
+	ListStateMachinesPagesWithContext(aws.Context, *sfn.ListStateMachinesInput, func(*sfn.ListStateMachinesOutput, bool) bool, ...request.Option) error
+	ListExecutionsWithContext(aws.Context, *sfn.ListExecutionsInput, ...request.Option) (*sfn.ListExecutionsOutput, error)
+	DescribeExecutionWithContext(aws.Context, *sfn.DescribeExecutionInput, ...request.Option) (*sfn.DescribeExecutionOutput, error)
+	GetExecutionHistoryPagesWithContext(aws.Context, *sfn.GetExecutionHistoryInput, func(*sfn.GetExecutionHistoryOutput, bool) bool, ...request.Option) error
+	ListTagsForResourceWithContext(aws.Context, *sfn.ListTagsForResourceInput, ...request.Option) (*sfn.ListTagsForResourceOutput, error)
+	ListMapRunsPagesWithContext(aws.Context, *sfn.ListMapRunsInput, func(*sfn.ListMapRunsOutput, bool) bool, ...request.Option) error
+	DescribeMapRunWithContext(aws.Context, *sfn.DescribeMapRunInput, ...request.Option) (*sfn.DescribeMapRunOutput, error)
+	ListStateMachineVersionsWithContext(aws.Context, *sfn.ListStateMachineVersionsInput, ...request.Option) (*sfn.ListStateMachineVersionsOutput, error)
+}
+
+type Options struct {
+	// StateMachineArn scopes the run to a single state machine instead of
+	// listing every state machine in the account.
+	StateMachineArn string
+	// Include, when non-empty, keeps only the state machines with these
+	// names. Exclude drops state machines by name and wins over Include. Both
+	// apply before any execution is fetched.
+	Include []string
+	Exclude []string
+	// Since drops executions that started, or stopped with FilterByStop,
+	// before it.
+	Since        time.Time
+	FilterByStop bool
+	// Location is the time zone of StartDate and StartTime. Defaults to UTC.
+	Location *time.Location
+	// Now is the clock the running time of stuck executions is measured
+	// against. Defaults to time.Now.
+	Now func() time.Time
+	// MaxRunning reports running executions older than it as stuck. Zero
+	// disables the check.
+	MaxRunning time.Duration
+	// KeepNegative keeps executions whose stop date is before their start
+	// date instead of skipping them.
+	KeepNegative bool
+	// Describe calls DescribeExecution once per execution to fill InputBytes,
+	// OutputBytes, Version and Alias. Executions whose call fails are
+	// skipped with a warning.
+	Describe bool
+	// ContainsState keeps only the executions whose history shows they
+	// entered the state of that name, walking the history of every
+	// execution. Executions whose history cannot be read are skipped with a
+	// warning.
+	ContainsState string
+	// Version and Alias keep only the executions of the given state machine
+	// version number or alias name. They require Describe.
+	Version string
+	Alias   string
+	// NameStyle is how the Name of each record is derived from the state
+	// machine ARN, see DisplayName. Include and Exclude still match the bare
+	// name. NameStyleNameVersion requires Describe. Empty means
+	// NameStyleName.
+	NameStyle string
+	// ChangedSince, when set, keeps only the state machines created after
+	// it, before any execution is fetched. With ChangedByVersion a state
+	// machine with a version published after it is kept too, at the cost of
+	// a ListStateMachineVersions call per older state machine. Neither
+	// applies to StateMachineArn.
+	ChangedSince     time.Time
+	ChangedByVersion bool
+	// ExcludeStatuses drops the executions with any of these statuses.
+	ExcludeStatuses []string
+	// ExecutionNamePrefix keeps only the executions whose name starts with it.
+	ExecutionNamePrefix string
+	// ListExecutionsInput, when set, is the template of every ListExecutions
+	// call. Collect sets the state machine ARN and the page token on a copy.
+	ListExecutionsInput *sfn.ListExecutionsInput
+	// MaxRecords, when positive, stops fetching executions once that many
+	// records have been collected across all state machines, and marks the
+	// Result as truncated. Zero means unlimited.
+	MaxRecords int
+	// Concurrency is the maximum number of API calls Collect has in flight.
+	// Values below 1 mean 1.
+	Concurrency int
+	// Warnf receives non-fatal problems such as skipped executions. It may
+	// be called concurrently.
+	Warnf func(format string, args ...any)
+	// Listed, when set, is called with the number of state machines to scan
+	// once they are listed, before any execution is fetched, e.g. to estimate
+	// how long the scan will take.
+	Listed func(machines int)
+}
+
+func (o Options) wantsMachine(name string) bool {
+	if slices.Contains(o.Exclude, name) {
+		return false
+	}
+	return len(o.Include) == 0 || slices.Contains(o.Include, name)
+}
+
+func (o Options) warnf(format string, args ...any) {
+	if o.Warnf != nil {
+		o.Warnf(format, args...)
+	}
+}
+
+type Result struct {
+	Records SfnRecords
+	// Stuck holds the running executions exceeding Options.MaxRunning.
+	Stuck []StuckExecution
+	// Idle holds the state machines that were measured but had no records
+	// left after filtering.
+	Idle []IdleMachine
+	// Anomalies holds the finished executions the API returned without a
+	// start or stop date, which have no duration and therefore no record.
+	Anomalies []AnomalousExecution
+	// Truncated is set when Options.MaxRecords stopped the collection early,
+	// so that some executions, and possibly whole state machines, are missing.
+	Truncated bool
+}
+
+type IdleMachine struct {
+	Name            string
+	StateMachineArn string
+}
+
+type AnomalousExecution struct {
+	Name         string
+	ExecutionArn string
+	Status       string
+	// Missing is the missing date: StartDate, StopDate or both.
+	Missing string
+}
+
+type StuckExecution struct {
+	Name         string
+	ExecutionArn string
+	StartTime    time.Time
+	Elapsed      time.Duration
+}
+
+// Measure returns a record for each finished execution of every state machine.
+func Measure(ctx context.Context, client Client, opts Options) (SfnRecords, error) {
+	result, err := Collect(ctx, client, opts)
+	return result.Records, err
+}
+
+// Collect lists every state machine and builds a record for each of its
+// finished executions. It stops fetching new pages once ctx is done and returns
+// whatever was collected up to that point along with the error.
+//
+// State machines are collected concurrently, and within a state machine the
+// DescribeExecution calls of one page overlap with fetching the next page.
+// ListExecutions pages are chained by their tokens, so they cannot themselves
+// be fetched in parallel. At most Options.Concurrency API calls are in flight
+// at a time. Records keep the order of the state machines and executions as
+// listed regardless of the concurrency.
+func Collect(ctx context.Context, client Client, opts Options) (Result, error) {
+	if opts.Location == nil {
+		opts.Location = time.UTC
+	}
+	if opts.Now == nil {
+		opts.Now = time.Now
+	}
+
+	machines, err := listStateMachines(ctx, client, opts)
+	if err != nil {
+		return Result{}, err
+	}
+	if opts.Listed != nil {
+		opts.Listed(len(machines))
+	}
+
+	c := &collector{
+		client:  client,
+		opts:    opts,
+		limiter: newLimiter(opts.Concurrency),
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	results := make([]machineResult, len(machines))
+	var wg sync.WaitGroup
+	for i, machine := range machines {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = c.collectMachine(ctx, machine)
+			if results[i].err != nil {
+				// Stop the other state machines early; the first error is
+				// returned below.
+				cancel()
+			}
+		}()
+	}
+	wg.Wait()
+
+	result := Result{Records: SfnRecords{}}
+	var firstErr error
+	for _, r := range results {
+		result.Records = append(result.Records, r.records...)
+		result.Stuck = append(result.Stuck, r.stuck...)
+		result.Idle = append(result.Idle, r.idle...)
+		result.Anomalies = append(result.Anomalies, r.anomalies...)
+		if firstErr == nil && r.err != nil && !errors.Is(r.err, context.Canceled) {
+			firstErr = r.err
+		}
+	}
+	if c.full() {
+		result.Truncated = true
+		result.Records = result.Records[:min(len(result.Records), opts.MaxRecords)]
+	}
+	if firstErr != nil {
+		return result, firstErr
+	}
+	return result, ctx.Err()
+}
+
+// Stream collects one state machine at a time and hands its records to emit
+// before moving on to the next one, so that only a single state machine's
+// records are held in memory. Options.Concurrency only applies to the
+// DescribeExecution calls within a state machine. The returned Result has the
+// stuck executions and idle state machines but no records.
+func Stream(ctx context.Context, client Client, opts Options, emit func(name string, records SfnRecords) error) (Result, error) {
+	if opts.Location == nil {
+		opts.Location = time.UTC
+	}
+	if opts.Now == nil {
+		opts.Now = time.Now
+	}
+
+	machines, err := listStateMachines(ctx, client, opts)
+	if err != nil {
+		return Result{}, err
+	}
+	if opts.Listed != nil {
+		opts.Listed(len(machines))
+	}
+
+	c := &collector{
+		client:  client,
+		opts:    opts,
+		limiter: newLimiter(opts.Concurrency),
+	}
+
+	var result Result
+	for _, machine := range machines {
+		if c.full() {
+			result.Truncated = true
+			break
+		}
+		r := c.collectMachine(ctx, machine)
+		result.Stuck = append(result.Stuck, r.stuck...)
+		result.Idle = append(result.Idle, r.idle...)
+		result.Anomalies = append(result.Anomalies, r.anomalies...)
+		if len(r.records) > 0 {
+			if err := emit(r.records[0].Name, r.records); err != nil {
+				return result, err
+			}
+		}
+		if r.err != nil {
+			return result, r.err
+		}
+	}
+	return result, nil
+}
+
+// collector holds the state shared by the state machines of one Collect call.
+type collector struct {
+	client  Client
+	opts    Options
+	limiter limiter
+	// collected counts the records built so far for Options.MaxRecords.
+	collected atomic.Int64
+}
+
+// full reports whether Options.MaxRecords records have been collected.
+func (c *collector) full() bool {
+	return c.opts.MaxRecords > 0 && c.collected.Load() >= int64(c.opts.MaxRecords)
+}
+
+// MachineError is a failure to list the executions, or the versions, of one
+// state machine.
+type MachineError struct {
+	Name            string
+	StateMachineArn string
+	Err             error
+}
+
+func (e *MachineError) Error() string {
+	return e.Name + ": " + e.Err.Error()
+}
+
+func (e *MachineError) Unwrap() error {
+	return e.Err
+}
+
+type machineResult struct {
+	records SfnRecords
+	stuck   []StuckExecution
+	// idle has the state machine when it is measured but has no records.
+	idle      []IdleMachine
+	anomalies []AnomalousExecution
+	err       error
+}
+
+func (c *collector) collectMachine(ctx context.Context, machine *sfn.StateMachineListItem) machineResult {
+	var result machineResult
+
+	name, err := StateMachineName(*machine.StateMachineArn)
+	if err != nil {
+		result.err = err
+		return result
+	}
+	if !c.opts.wantsMachine(name) {
+		return result
+	}
+
+	// Each page's records are described in place by a goroutine of their
+	// own, so a batch is never appended to once its goroutine has started.
+	// keep marks the records surviving the version and alias filters.
+	type batch struct {
+		records SfnRecords
+		keep    []bool
+	}
+	var batches []batch
+	var wg sync.WaitGroup
+
+	input := &sfn.ListExecutionsInput{}
+	if c.opts.ListExecutionsInput != nil {
+		*input = *c.opts.ListExecutionsInput
+	}
+	input.StateMachineArn = machine.StateMachineArn
+	for result.err == nil && !c.full() {
+		if err := c.limiter.acquire(ctx); err != nil {
+			result.err = err
+			break
+		}
+		page, err := c.client.ListExecutionsWithContext(ctx, input)
+		c.limiter.release()
+		if err != nil {
+			result.err = &MachineError{Name: name, StateMachineArn: *machine.StateMachineArn, Err: err}
+			break
+		}
+
+		var b batch
+		for _, execution := range page.Executions {
+			if stuck, ok := c.stuckExecution(name, execution); ok {
+				result.stuck = append(result.stuck, stuck)
+			}
+			if anomaly, ok := c.anomalousExecution(name, execution); ok {
+				result.anomalies = append(result.anomalies, anomaly)
+			}
+			if record, ok := c.buildRecord(name, machine, execution); ok {
+				b.records = append(b.records, record)
+				b.keep = append(b.keep, true)
+			}
+		}
+		batches = append(batches, b)
+		c.collected.Add(int64(len(b.records)))
+
+		if (c.opts.Describe || c.opts.ContainsState != "") && len(b.records) > 0 {
+			// Describe the page in the background so that listing the next
+			// page overlaps with it; both draw from the same limiter.
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if c.opts.Describe {
+					c.describeBatch(ctx, b.records, b.keep)
+				}
+				if c.opts.ContainsState != "" {
+					c.filterByState(ctx, b.records, b.keep)
+				}
+			}()
+		}
+
+		if page.NextToken == nil || ctx.Err() != nil {
+			break
+		}
+		input.NextToken = page.NextToken
+	}
+	wg.Wait()
+
+	if result.err == nil {
+		result.err = ctx.Err()
+	}
+
+	for _, b := range batches {
+		for i, record := range b.records {
+			if b.keep[i] {
+				if c.opts.NameStyle != "" {
+					record.Name = DisplayName(record.StateMachineArn, record.Version, c.opts.NameStyle)
+				}
+				result.records = append(result.records, record)
+			}
+		}
+	}
+	// A state machine cut off by MaxRecords is not known to be idle.
+	if len(result.records) == 0 && result.err == nil && !c.full() {
+		result.idle = []IdleMachine{{Name: name, StateMachineArn: aws.StringValue(machine.StateMachineArn)}}
+	}
+	return result
+}
+
+// describeBatch describes the records in place and clears keep for those
+// failing the version and alias filters. A record whose call failed is
+// dropped with a warning rather than failing the whole run.
+func (c *collector) describeBatch(ctx context.Context, records SfnRecords, keep []bool) {
+	arns := make([]string, len(records))
+	for i, record := range records {
+		arns[i] = record.ExecutionArn
+	}
+
+	for i, r := range c.limiter.describeExecutions(ctx, c.client, arns) {
+		if r.Err != nil {
+			if ctx.Err() == nil {
+				c.opts.warnf("skipping %s: %v", arns[i], r.Err)
+			}
+			keep[i] = false
+			continue
+		}
+		applyDescribe(&records[i], r.Output)
+		keep[i] = c.opts.matchesVersion(records[i])
+	}
+}
+
+// filterByState clears keep for the kept records whose execution never
+// entered Options.ContainsState. Each history walk holds one limiter slot for
+// all of its pages.
+func (c *collector) filterByState(ctx context.Context, records SfnRecords, keep []bool) {
+	var wg sync.WaitGroup
+	for i, record := range records {
+		if !keep[i] {
+			continue
+		}
+		if err := c.limiter.acquire(ctx); err != nil {
+			// The rest is unchecked and therefore not kept.
+			for j := i; j < len(keep); j++ {
+				keep[j] = false
+			}
+			break
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer c.limiter.release()
+			entered, err := EnteredState(ctx, c.client, record.ExecutionArn, c.opts.ContainsState)
+			if err != nil {
+				if ctx.Err() == nil {
+					c.opts.warnf("skipping %s: %v", record.ExecutionArn, err)
+				}
+				keep[i] = false
+				return
+			}
+			keep[i] = entered
+		}()
+	}
+	wg.Wait()
+}
+
+// stuckExecution reports a running execution older than Options.MaxRunning.
+func (c *collector) stuckExecution(name string, execution *sfn.ExecutionListItem) (StuckExecution, bool) {
+	if c.opts.MaxRunning <= 0 || execution.StartDate == nil || aws.StringValue(execution.Status) != sfn.ExecutionStatusRunning {
+		return StuckExecution{}, false
+	}
+	elapsed := c.opts.Now().Sub(*execution.StartDate)
+	if elapsed <= c.opts.MaxRunning {
+		return StuckExecution{}, false
+	}
+	return StuckExecution{
+		Name:         name,
+		ExecutionArn: aws.StringValue(execution.ExecutionArn),
+		StartTime:    execution.StartDate.In(c.opts.Location),
+		Elapsed:      elapsed,
+	}, true
+}
+
+// anomalousExecution reports an execution that is not running but lacks its
+// start or stop date, a rare inconsistency of the API. The status and name
+// filters apply, and Since too when the start date is there.
+func (c *collector) anomalousExecution(name string, execution *sfn.ExecutionListItem) (AnomalousExecution, bool) {
+	if slices.Contains(c.opts.ExcludeStatuses, aws.StringValue(execution.Status)) ||
+		!strings.HasPrefix(aws.StringValue(execution.Name), c.opts.ExecutionNamePrefix) ||
+		execution.StartDate != nil && execution.StartDate.Before(c.opts.Since) {
+		return AnomalousExecution{}, false
+	}
+
+	var missing []string
+	if execution.StartDate == nil {
+		missing = append(missing, "StartDate")
+	}
+	if execution.StopDate == nil && aws.StringValue(execution.Status) != sfn.ExecutionStatusRunning {
+		missing = append(missing, "StopDate")
+	}
+	if len(missing) == 0 {
+		return AnomalousExecution{}, false
+	}
+	return AnomalousExecution{
+		Name:         name,
+		ExecutionArn: aws.StringValue(execution.ExecutionArn),
+		Status:       aws.StringValue(execution.Status),
+		Missing:      strings.Join(missing, "+"),
+	}, true
+}
+
+// buildRecord returns the record of a finished execution, or false when the
+// execution is still running or filtered out.
+func (c *collector) buildRecord(name string, machine *sfn.StateMachineListItem, execution *sfn.ExecutionListItem) (SfnRecord, bool) {
+	opts := c.opts
+
+	if execution.StartDate == nil || execution.StopDate == nil || !c.matchesExecution(execution) {
+		return SfnRecord{}, false
+	}
+
+	duration := execution.StopDate.Sub(*execution.StartDate)
+	if duration < 0 && !opts.KeepNegative {
+		opts.warnf("skipping %s: stop date is before start date (%s)", aws.StringValue(execution.ExecutionArn), duration)
+		return SfnRecord{}, false
+	}
+
+	startTime := execution.StartDate.In(opts.Location)
+	return SfnRecord{
+		Name:            name,
+		StateMachineArn: aws.StringValue(machine.StateMachineArn),
+		Region:          Region(aws.StringValue(machine.StateMachineArn)),
+		ExecutionName:   aws.StringValue(execution.Name),
+		ExecutionArn:    aws.StringValue(execution.ExecutionArn),
+		StartDate:       startTime.Format(time.DateOnly),
+		StartTime:       startTime,
+		Duration:        duration,
+		Status:          *execution.Status,
+	}, true
+}
+
+// matchesExecution reports whether the execution passes the status, name
+// prefix and Since filters. With FilterByStop, running executions never pass.
+func (c *collector) matchesExecution(execution *sfn.ExecutionListItem) bool {
+	opts := c.opts
+
+	if slices.Contains(opts.ExcludeStatuses, aws.StringValue(execution.Status)) {
+		return false
+	}
+
+	if !strings.HasPrefix(aws.StringValue(execution.Name), opts.ExecutionNamePrefix) {
+		return false
+	}
+
+	filtered := execution.StartDate
+	if opts.FilterByStop {
+		filtered = execution.StopDate
+	}
+	return filtered != nil && !filtered.Before(opts.Since)
+}
+
+func listStateMachines(ctx context.Context, client Client, opts Options) ([]*sfn.StateMachineListItem, error) {
+	if opts.StateMachineArn != "" {
+		return []*sfn.StateMachineListItem{{StateMachineArn: aws.String(opts.StateMachineArn)}}, nil
+	}
+
+	var machines []*sfn.StateMachineListItem
+	err := client.ListStateMachinesPagesWithContext(ctx, &sfn.ListStateMachinesInput{}, func(page *sfn.ListStateMachinesOutput, _ bool) bool {
+		machines = append(machines, page.StateMachines...)
+		return ctx.Err() == nil
+	})
+	if err != nil || opts.ChangedSince.IsZero() {
+		return machines, err
+	}
+	return changedMachines(ctx, client, opts, machines)
+}
+
+// changedMachines keeps the state machines created, or with ChangedByVersion
+// versioned, after Options.ChangedSince. DescribeStateMachine has no update
+// time, so the most recent version is the only trace of an update.
+func changedMachines(ctx context.Context, client Client, opts Options, machines []*sfn.StateMachineListItem) ([]*sfn.StateMachineListItem, error) {
+	var changed []*sfn.StateMachineListItem
+	for _, machine := range machines {
+		if aws.TimeValue(machine.CreationDate).After(opts.ChangedSince) {
+			changed = append(changed, machine)
+			continue
+		}
+		if !opts.ChangedByVersion {
+			continue
+		}
+
+		// Versions are listed newest first.
+		out, err := client.ListStateMachineVersionsWithContext(ctx, &sfn.ListStateMachineVersionsInput{
+			StateMachineArn: machine.StateMachineArn,
+			MaxResults:      aws.Int64(1),
+		})
+		if err != nil {
+			return nil, &MachineError{Name: aws.StringValue(machine.Name), StateMachineArn: aws.StringValue(machine.StateMachineArn), Err: err}
+		}
+		if len(out.StateMachineVersions) > 0 && aws.TimeValue(out.StateMachineVersions[0].CreationDate).After(opts.ChangedSince) {
+			changed = append(changed, machine)
+		}
+	}
+	return changed, nil
+}
+
+func (o Options) matchesVersion(record SfnRecord) bool {
+	if o.Version != "" && record.Version != o.Version {
+		return false
+	}
+	if o.Alias != "" && record.Alias != o.Alias {
+		return false
+	}
+	return true
+}
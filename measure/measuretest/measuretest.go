@@ -0,0 +1,362 @@
+// Package measuretest provides an in-memory SFN client for exercising the
+// measure package without AWS, e.g. in benchmarks of the concurrency and
+// streaming paths.
+package measuretest
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/Finatext/measure-sfn/measure"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/sfn"
+)
+
+const (
+	defaultPageSize = 100
+	account         = "123456789012"
+	region          = "us-east-1"
+)
+
+// Machine is a state machine served by Client.
+type Machine struct {
+	Arn          string
+	CreationDate time.Time
+	Executions   []*sfn.ExecutionListItem
+	Tags         map[string]string
+	// Versions holds the creation dates of the published versions, newest
+	// first as listed by ListStateMachineVersions.
+	Versions []time.Time
+	// History holds the events returned by GetExecutionHistory per execution
+	// ARN. Executions without an entry have an empty history.
+	History map[string][]*sfn.HistoryEvent
+	// MapRuns holds the Distributed Map runs started per execution ARN.
+	MapRuns map[string][]*sfn.DescribeMapRunOutput
+}
+
+// Client implements measure.Client over Machines. Configure it before use;
+// it is safe for concurrent use afterwards.
+type Client struct {
+	Machines []Machine
+	// PageSize is the number of items per page of the list calls. Defaults
+	// to 100.
+	PageSize int
+	// Latency is slept on every call to simulate the network.
+	Latency time.Duration
+	// ThrottleEvery, when positive, fails every ThrottleEvery-th call with a
+	// ThrottlingException, including each page of the paginated calls. The
+	// error is returned to the caller as is: the SDK retryer only wraps real
+	// clients, so this exercises how measure handles a failing call rather
+	// than the retries.
+	ThrottleEvery int
+
+	calls atomic.Int64
+}
+
+var _ measure.Client = (*Client)(nil)
+
+// New returns a Client with machines state machines of executions executions
+// each. duration and status give the duration and status of the j-th
+// execution of the i-th state machine; a nil duration means one second and a
+// nil status means SUCCEEDED. The state machines were created a year ago and
+// have no versions. Executions start an hour apart going back from
+// now, so the most recent comes first as in the real API.
+func New(machines, executions int, duration func(i, j int) time.Duration, status func(i, j int) string) *Client {
+	if duration == nil {
+		duration = func(int, int) time.Duration { return time.Second }
+	}
+	if status == nil {
+		status = func(int, int) string { return sfn.ExecutionStatusSucceeded }
+	}
+
+	now := time.Now().UTC().Truncate(time.Second)
+	c := &Client{Machines: make([]Machine, machines)}
+	for i := range c.Machines {
+		name := fmt.Sprintf("machine-%d", i)
+		m := Machine{
+			Arn:          fmt.Sprintf("arn:aws:states:%s:%s:stateMachine:%s", region, account, name),
+			CreationDate: now.AddDate(-1, 0, 0),
+		}
+		for j := 0; j < executions; j++ {
+			start := now.Add(-time.Duration(j+1) * time.Hour)
+			execution := &sfn.ExecutionListItem{
+				ExecutionArn:    aws.String(fmt.Sprintf("arn:aws:states:%s:%s:execution:%s:execution-%d", region, account, name, j)),
+				Name:            aws.String(fmt.Sprintf("execution-%d", j)),
+				StateMachineArn: aws.String(m.Arn),
+				StartDate:       aws.Time(start),
+				Status:          aws.String(status(i, j)),
+			}
+			if aws.StringValue(execution.Status) != sfn.ExecutionStatusRunning {
+				execution.StopDate = aws.Time(start.Add(duration(i, j)))
+			}
+			m.Executions = append(m.Executions, execution)
+		}
+		c.Machines[i] = m
+	}
+	return c
+}
+
+// Calls returns the number of API calls made so far, including throttled ones.
+func (c *Client) Calls() int64 {
+	return c.calls.Load()
+}
+
+func (c *Client) call(ctx aws.Context) error {
+	n := c.calls.Add(1)
+	if c.Latency > 0 {
+		select {
+		case <-time.After(c.Latency):
+		case <-ctx.Done():
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if c.ThrottleEvery > 0 && n%int64(c.ThrottleEvery) == 0 {
+		return awserr.New("ThrottlingException", "Rate exceeded", nil)
+	}
+	return nil
+}
+
+func (c *Client) pageSize() int {
+	if c.PageSize > 0 {
+		return c.PageSize
+	}
+	return defaultPageSize
+}
+
+// page returns the bounds of the page starting at token out of n items and
+// the token of the next page, nil on the last one.
+func (c *Client) page(token *string, n int) (int, int, *string, error) {
+	start := 0
+	if token != nil {
+		var err error
+		if start, err = strconv.Atoi(*token); err != nil || start < 0 || start > n {
+			return 0, 0, nil, awserr.New(sfn.ErrCodeInvalidToken, "invalid token", nil)
+		}
+	}
+	end := min(start+c.pageSize(), n)
+	if end == n {
+		return start, end, nil, nil
+	}
+	return start, end, aws.String(strconv.Itoa(end)), nil
+}
+
+func (c *Client) machine(arn string) (*Machine, error) {
+	for i := range c.Machines {
+		if c.Machines[i].Arn == arn {
+			return &c.Machines[i], nil
+		}
+	}
+	return nil, awserr.New(sfn.ErrCodeStateMachineDoesNotExist, "state machine does not exist: "+arn, nil)
+}
+
+func (c *Client) execution(arn string) (*Machine, *sfn.ExecutionListItem, error) {
+	for i := range c.Machines {
+		for _, execution := range c.Machines[i].Executions {
+			if aws.StringValue(execution.ExecutionArn) == arn {
+				return &c.Machines[i], execution, nil
+			}
+		}
+	}
+	return nil, nil, awserr.New(sfn.ErrCodeExecutionDoesNotExist, "execution does not exist: "+arn, nil)
+}
+
+func (c *Client) ListStateMachinesPagesWithContext(ctx aws.Context, input *sfn.ListStateMachinesInput, fn func(*sfn.ListStateMachinesOutput, bool) bool, _ ...request.Option) error {
+	token := input.NextToken
+	for {
+		if err := c.call(ctx); err != nil {
+			return err
+		}
+		start, end, next, err := c.page(token, len(c.Machines))
+		if err != nil {
+			return err
+		}
+
+		out := &sfn.ListStateMachinesOutput{NextToken: next}
+		for _, m := range c.Machines[start:end] {
+			name, _ := measure.StateMachineName(m.Arn)
+			out.StateMachines = append(out.StateMachines, &sfn.StateMachineListItem{
+				StateMachineArn: aws.String(m.Arn),
+				Name:            aws.String(name),
+				Type:            aws.String(sfn.StateMachineTypeStandard),
+				CreationDate:    aws.Time(m.CreationDate),
+			})
+		}
+		if !fn(out, next == nil) || next == nil {
+			return nil
+		}
+		token = next
+	}
+}
+
+// ListExecutionsWithContext honors StatusFilter in addition to pagination.
+func (c *Client) ListExecutionsWithContext(ctx aws.Context, input *sfn.ListExecutionsInput, _ ...request.Option) (*sfn.ListExecutionsOutput, error) {
+	if err := c.call(ctx); err != nil {
+		return nil, err
+	}
+	m, err := c.machine(aws.StringValue(input.StateMachineArn))
+	if err != nil {
+		return nil, err
+	}
+
+	executions := m.Executions
+	if input.StatusFilter != nil {
+		executions = nil
+		for _, execution := range m.Executions {
+			if aws.StringValue(execution.Status) == *input.StatusFilter {
+				executions = append(executions, execution)
+			}
+		}
+	}
+
+	start, end, next, err := c.page(input.NextToken, len(executions))
+	if err != nil {
+		return nil, err
+	}
+	return &sfn.ListExecutionsOutput{Executions: executions[start:end], NextToken: next}, nil
+}
+
+func (c *Client) DescribeExecutionWithContext(ctx aws.Context, input *sfn.DescribeExecutionInput, _ ...request.Option) (*sfn.DescribeExecutionOutput, error) {
+	if err := c.call(ctx); err != nil {
+		return nil, err
+	}
+	_, execution, err := c.execution(aws.StringValue(input.ExecutionArn))
+	if err != nil {
+		return nil, err
+	}
+
+	return &sfn.DescribeExecutionOutput{
+		ExecutionArn:    execution.ExecutionArn,
+		Name:            execution.Name,
+		StateMachineArn: execution.StateMachineArn,
+		StartDate:       execution.StartDate,
+		StopDate:        execution.StopDate,
+		Status:          execution.Status,
+		Input:           aws.String("{}"),
+		InputDetails:    &sfn.CloudWatchEventsExecutionDataDetails{Included: aws.Bool(true)},
+		Output:          aws.String("{}"),
+		OutputDetails:   &sfn.CloudWatchEventsExecutionDataDetails{Included: aws.Bool(true)},
+	}, nil
+}
+
+func (c *Client) GetExecutionHistoryPagesWithContext(ctx aws.Context, input *sfn.GetExecutionHistoryInput, fn func(*sfn.GetExecutionHistoryOutput, bool) bool, _ ...request.Option) error {
+	m, _, err := c.execution(aws.StringValue(input.ExecutionArn))
+	if err != nil {
+		return err
+	}
+	events := m.History[aws.StringValue(input.ExecutionArn)]
+	if aws.BoolValue(input.ReverseOrder) {
+		events = slices.Clone(events)
+		slices.Reverse(events)
+	}
+
+	token := input.NextToken
+	for {
+		if err := c.call(ctx); err != nil {
+			return err
+		}
+		start, end, next, err := c.page(token, len(events))
+		if err != nil {
+			return err
+		}
+		if !fn(&sfn.GetExecutionHistoryOutput{Events: events[start:end], NextToken: next}, next == nil) || next == nil {
+			return nil
+		}
+		token = next
+	}
+}
+
+func (c *Client) ListTagsForResourceWithContext(ctx aws.Context, input *sfn.ListTagsForResourceInput, _ ...request.Option) (*sfn.ListTagsForResourceOutput, error) {
+	if err := c.call(ctx); err != nil {
+		return nil, err
+	}
+	m, err := c.machine(aws.StringValue(input.ResourceArn))
+	if err != nil {
+		return nil, err
+	}
+
+	out := &sfn.ListTagsForResourceOutput{}
+	for key, value := range m.Tags {
+		out.Tags = append(out.Tags, &sfn.Tag{Key: aws.String(key), Value: aws.String(value)})
+	}
+	return out, nil
+}
+
+func (c *Client) ListMapRunsPagesWithContext(ctx aws.Context, input *sfn.ListMapRunsInput, fn func(*sfn.ListMapRunsOutput, bool) bool, _ ...request.Option) error {
+	m, _, err := c.execution(aws.StringValue(input.ExecutionArn))
+	if err != nil {
+		return err
+	}
+	runs := m.MapRuns[aws.StringValue(input.ExecutionArn)]
+
+	token := input.NextToken
+	for {
+		if err := c.call(ctx); err != nil {
+			return err
+		}
+		start, end, next, err := c.page(token, len(runs))
+		if err != nil {
+			return err
+		}
+
+		out := &sfn.ListMapRunsOutput{NextToken: next}
+		for _, run := range runs[start:end] {
+			out.MapRuns = append(out.MapRuns, &sfn.MapRunListItem{
+				ExecutionArn:    input.ExecutionArn,
+				MapRunArn:       run.MapRunArn,
+				StartDate:       run.StartDate,
+				StopDate:        run.StopDate,
+				StateMachineArn: aws.String(m.Arn),
+			})
+		}
+		if !fn(out, next == nil) || next == nil {
+			return nil
+		}
+		token = next
+	}
+}
+
+func (c *Client) DescribeMapRunWithContext(ctx aws.Context, input *sfn.DescribeMapRunInput, _ ...request.Option) (*sfn.DescribeMapRunOutput, error) {
+	if err := c.call(ctx); err != nil {
+		return nil, err
+	}
+	for _, m := range c.Machines {
+		for _, runs := range m.MapRuns {
+			for _, run := range runs {
+				if aws.StringValue(run.MapRunArn) == aws.StringValue(input.MapRunArn) {
+					return run, nil
+				}
+			}
+		}
+	}
+	return nil, awserr.New(sfn.ErrCodeResourceNotFound, "map run does not exist: "+aws.StringValue(input.MapRunArn), nil)
+}
+
+func (c *Client) ListStateMachineVersionsWithContext(ctx aws.Context, input *sfn.ListStateMachineVersionsInput, _ ...request.Option) (*sfn.ListStateMachineVersionsOutput, error) {
+	if err := c.call(ctx); err != nil {
+		return nil, err
+	}
+	m, err := c.machine(aws.StringValue(input.StateMachineArn))
+	if err != nil {
+		return nil, err
+	}
+
+	versions := m.Versions
+	if n := int(aws.Int64Value(input.MaxResults)); n > 0 && n < len(versions) {
+		versions = versions[:n]
+	}
+	out := &sfn.ListStateMachineVersionsOutput{}
+	for i, created := range versions {
+		out.StateMachineVersions = append(out.StateMachineVersions, &sfn.StateMachineVersionListItem{
+			StateMachineVersionArn: aws.String(fmt.Sprintf("%s:%d", m.Arn, len(m.Versions)-i)),
+			CreationDate:           aws.Time(created),
+		})
+	}
+	return out, nil
+}
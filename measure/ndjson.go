@@ -0,0 +1,64 @@
+package measure
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// ReadNDJSON reads back records previously written to sfn.ndjson by
+// measure-sfn, such as a --stream archive, with the same columns as ReadCSV.
+// A malformed line does not fail the whole file: it is skipped and reported
+// in skipped, one error per line. Blank lines are ignored.
+func ReadNDJSON(r io.Reader) (records SfnRecords, skipped []error, err error) {
+	records = SfnRecords{}
+	scanner := bufio.NewScanner(r)
+	// A record with a long failure cause can exceed the default line limit.
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+		record, err := parseNDJSONRecord(scanner.Bytes())
+		if err != nil {
+			skipped = append(skipped, fmt.Errorf("line %d: %w", line, err))
+			continue
+		}
+		records = append(records, record)
+	}
+	return records, skipped, scanner.Err()
+}
+
+func parseNDJSONRecord(line []byte) (SfnRecord, error) {
+	var object map[string]json.RawMessage
+	if err := json.Unmarshal(line, &object); err != nil {
+		return SfnRecord{}, err
+	}
+
+	// Numbers are read back verbatim so that durations keep their precision.
+	values := make(map[string]string, len(object))
+	for name, raw := range object {
+		var s string
+		if err := json.Unmarshal(raw, &s); err == nil {
+			values[name] = s
+			continue
+		}
+		var n json.Number
+		if err := json.Unmarshal(raw, &n); err != nil {
+			return SfnRecord{}, fmt.Errorf("invalid %s: %w", name, err)
+		}
+		values[name] = n.String()
+	}
+
+	if _, ok := values["Name"]; ok {
+		for name, unit := range durationColumns {
+			if duration, ok := values[name]; ok {
+				return parseRecord(func(name string) string { return values[name] }, duration, unit)
+			}
+		}
+	}
+	return SfnRecord{}, errors.New("not a record: the Name and Duration fields are required")
+}
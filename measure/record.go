@@ -0,0 +1,522 @@
+package measure
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/sfn"
+)
+
+type SfnRecord struct {
+	Name            string        `csv:"Name"`
+	StartDate       string        `csv:"StartDate"`
+	Duration        time.Duration `csv:"Duration"`
+	Status          string        `csv:"Status"`
+	StateMachineArn string        `csv:"-"`
+	// Region is the region of the state machine.
+	Region        string `csv:"-"`
+	ExecutionName string `csv:"-"`
+	ExecutionArn  string `csv:"-"`
+	// Account and AccountName identify the AWS account of the state machine.
+	// They are only populated by the caller, e.g. when measuring an
+	// organization.
+	Account     string `csv:"-"`
+	AccountName string `csv:"-"`
+	// Env is the name of the environment the record was collected from. It
+	// is only populated by the caller.
+	Env string `csv:"-"`
+	// StartTime is the full start timestamp in Options.Location.
+	StartTime time.Time `csv:"-"`
+	// InputBytes, OutputBytes, Version and Alias are only populated when
+	// Options.Describe is set.
+	InputBytes  int64  `csv:"InputBytes"`
+	OutputBytes int64  `csv:"OutputBytes"`
+	Version     string `csv:"Version"`
+	Alias       string `csv:"-"`
+	// MapRuns and MapItems count the Distributed Map runs of the execution
+	// and the items they processed. They are only populated by CountMapItems.
+	MapRuns  int   `csv:"-"`
+	MapItems int64 `csv:"-"`
+	// ChildExecutions counts the executions started by startExecution Task
+	// states, and ParentName and ParentExecutionArn identify the execution
+	// that started this one. They are only populated by LinkChildren.
+	ChildExecutions    int    `csv:"-"`
+	ParentName         string `csv:"-"`
+	ParentExecutionArn string `csv:"-"`
+	// Error and Cause are the failure reason of a failed execution. They are
+	// populated by Options.Describe and DescribeFailures.
+	Error string `csv:"-"`
+	Cause string `csv:"-"`
+}
+
+// SfnRecords computes its statistics on the full-precision durations; only
+// the output formatting rounds them, so values that differ by nanoseconds
+// keep distinct averages and percentiles.
+type SfnRecords []SfnRecord
+
+// MaxExecution returns the record with the longest duration, the first one on
+// ties, or the zero SfnRecord when there are no records.
+func (r SfnRecords) MaxExecution() SfnRecord {
+	if len(r) == 0 {
+		return SfnRecord{}
+	}
+	max := r[0]
+	for _, record := range r {
+		if record.Duration > max.Duration {
+			max = record
+		}
+	}
+	return max
+}
+
+// MinExecution returns the record with the shortest duration, the first one
+// on ties, or the zero SfnRecord when there are no records.
+func (r SfnRecords) MinExecution() SfnRecord {
+	if len(r) == 0 {
+		return SfnRecord{}
+	}
+	min := r[0]
+	for _, record := range r {
+		if record.Duration < min.Duration {
+			min = record
+		}
+	}
+	return min
+}
+
+func (r SfnRecords) MaxDuration() time.Duration {
+	return r.MaxExecution().Duration
+}
+
+func (r SfnRecords) MinDuration() time.Duration {
+	return r.MinExecution().Duration
+}
+
+func (r SfnRecords) TotalDuration() time.Duration {
+	total := time.Duration(0)
+	for _, record := range r {
+		total += record.Duration
+	}
+	return total
+}
+
+func (r SfnRecords) AvgDuration() time.Duration {
+	return r.TotalDuration() / time.Duration(len(r))
+}
+
+// Percentile returns the p-th percentile (0-100) of the durations, linearly
+// interpolating between the closest ranks to the nearest nanosecond. It
+// returns 0 for no records.
+func (r SfnRecords) Percentile(p float64) time.Duration {
+	if len(r) == 0 {
+		return 0
+	}
+
+	durations := make([]time.Duration, len(r))
+	for i, record := range r {
+		durations[i] = record.Duration
+	}
+	sort.Slice(durations, func(i, j int) bool { return durations[i] < durations[j] })
+
+	rank := p / 100 * float64(len(durations)-1)
+	lower, upper := int(math.Floor(rank)), int(math.Ceil(rank))
+	frac := rank - float64(lower)
+	return durations[lower] + time.Duration(math.Round(frac*float64(durations[upper]-durations[lower])))
+}
+
+// StdDev returns the population standard deviation of the durations.
+func (r SfnRecords) StdDev() time.Duration {
+	if len(r) == 0 {
+		return 0
+	}
+
+	mean := float64(r.AvgDuration())
+	sum := 0.0
+	for _, record := range r {
+		d := float64(record.Duration) - mean
+		sum += d * d
+	}
+	return time.Duration(math.Sqrt(sum / float64(len(r))))
+}
+
+// CoefficientOfVariation returns the standard deviation relative to the mean,
+// or 0 when the mean is zero.
+func (r SfnRecords) CoefficientOfVariation() float64 {
+	if len(r) == 0 {
+		return 0
+	}
+
+	mean := r.AvgDuration()
+	if mean == 0 {
+		return 0
+	}
+	return float64(r.StdDev()) / float64(mean)
+}
+
+// TailRatio returns the 95th percentile relative to the mean, which is well
+// above 1 for state machines with a long tail, or 0 when the mean is zero.
+func (r SfnRecords) TailRatio() float64 {
+	if len(r) == 0 {
+		return 0
+	}
+
+	mean := r.AvgDuration()
+	if mean == 0 {
+		return 0
+	}
+	return float64(r.Percentile(95)) / float64(mean)
+}
+
+// Outliers counts the records longer than the upper Tukey fence, 1.5
+// interquartile ranges above the 75th percentile.
+func (r SfnRecords) Outliers() int {
+	q1, q3 := r.Percentile(25), r.Percentile(75)
+	fence := q3 + (q3-q1)*3/2
+	n := 0
+	for _, record := range r {
+		if record.Duration > fence {
+			n++
+		}
+	}
+	return n
+}
+
+// Span returns the time from the earliest start to the latest stop of the
+// records, or 0 when there are none.
+func (r SfnRecords) Span() time.Duration {
+	if len(r) == 0 {
+		return 0
+	}
+	first, last := r[0].StartTime, r[0].StartTime.Add(r[0].Duration)
+	for _, record := range r {
+		if record.StartTime.Before(first) {
+			first = record.StartTime
+		}
+		if stop := record.StartTime.Add(record.Duration); stop.After(last) {
+			last = stop
+		}
+	}
+	return last.Sub(first)
+}
+
+// PeakConcurrency returns the largest number of executions running at the
+// same time, sweeping over the start and stop times in order. An execution
+// stopping at the instant another starts does not overlap it.
+func (r SfnRecords) PeakConcurrency() int {
+	type event struct {
+		at    time.Time
+		delta int
+	}
+	events := make([]event, 0, 2*len(r))
+	for _, record := range r {
+		events = append(events, event{record.StartTime, 1}, event{record.StartTime.Add(record.Duration), -1})
+	}
+	sort.Slice(events, func(i, j int) bool {
+		if !events[i].at.Equal(events[j].at) {
+			return events[i].at.Before(events[j].at)
+		}
+		// Stops sort before starts at the same instant.
+		return events[i].delta < events[j].delta
+	})
+
+	running, peak := 0, 0
+	for _, e := range events {
+		running += e.delta
+		peak = max(peak, running)
+	}
+	return peak
+}
+
+// Utilization returns the total duration as a fraction of window, i.e. how
+// much of the wall-clock time the records spent executing. Concurrent
+// executions count separately and can push it above 1. It returns 0 for an
+// empty window.
+func (r SfnRecords) Utilization(window time.Duration) float64 {
+	if window <= 0 {
+		return 0
+	}
+	return float64(r.TotalDuration()) / float64(window)
+}
+
+// Stats returns the exact statistics of the records; Accumulator.Result is the
+// running equivalent. The average is 0 for no records.
+func (r SfnRecords) Stats() AggregateStats {
+	stats := AggregateStats{
+		Count: len(r),
+		Total: r.TotalDuration(),
+		Min:   r.MinDuration(),
+		Max:   r.MaxDuration(),
+		P50:   r.Percentile(50),
+		P95:   r.Percentile(95),
+		P99:   r.Percentile(99),
+	}
+	if len(r) > 0 {
+		stats.Avg = r.AvgDuration()
+	}
+	return stats
+}
+
+// NonZero returns the records with a non-zero duration, leaving out those
+// whose start and stop times are equal.
+func (r SfnRecords) NonZero() SfnRecords {
+	kept := make(SfnRecords, 0, len(r))
+	for _, record := range r {
+		if record.Duration != 0 {
+			kept = append(kept, record)
+		}
+	}
+	return kept
+}
+
+// SkipFirst leaves out the n earliest started executions of each state
+// machine, e.g. the cold starts after a deploy, keeping the order of the
+// rest.
+func (r SfnRecords) SkipFirst(n int) SfnRecords {
+	if n <= 0 {
+		return r
+	}
+
+	byName := make(map[string][]int)
+	for i, record := range r {
+		byName[record.Name] = append(byName[record.Name], i)
+	}
+	skipped := make([]bool, len(r))
+	for _, indexes := range byName {
+		sort.SliceStable(indexes, func(i, j int) bool { return r[indexes[i]].StartTime.Before(r[indexes[j]].StartTime) })
+		for _, i := range indexes[:min(n, len(indexes))] {
+			skipped[i] = true
+		}
+	}
+
+	kept := make(SfnRecords, 0, len(r))
+	for i, record := range r {
+		if !skipped[i] {
+			kept = append(kept, record)
+		}
+	}
+	return kept
+}
+
+// PercentileBand keeps the records of each state machine whose duration is
+// between its low and high percentiles, e.g. 95 and 100 for the slowest 5%,
+// keeping the order.
+func (r SfnRecords) PercentileBand(low, high float64) SfnRecords {
+	byName := make(map[string]SfnRecords)
+	for _, record := range r {
+		byName[record.Name] = append(byName[record.Name], record)
+	}
+	type bounds struct{ low, high time.Duration }
+	thresholds := make(map[string]bounds, len(byName))
+	for name, records := range byName {
+		thresholds[name] = bounds{records.Percentile(low), records.Percentile(high)}
+	}
+
+	kept := make(SfnRecords, 0, len(r))
+	for _, record := range r {
+		if b := thresholds[record.Name]; record.Duration >= b.low && record.Duration <= b.high {
+			kept = append(kept, record)
+		}
+	}
+	return kept
+}
+
+func (r SfnRecords) Len() int {
+	return len(r)
+}
+
+// ActiveDays returns the number of distinct start dates.
+func (r SfnRecords) ActiveDays() int {
+	days := make(map[string]struct{})
+	for _, record := range r {
+		days[record.StartDate] = struct{}{}
+	}
+	return len(days)
+}
+
+// Slowest returns the n records with the longest durations, longest first,
+// breaking ties by the earlier start time.
+func (r SfnRecords) Slowest(n int) SfnRecords {
+	sorted := slices.Clone(r)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].Duration != sorted[j].Duration {
+			return sorted[i].Duration > sorted[j].Duration
+		}
+		return sorted[i].StartTime.Before(sorted[j].StartTime)
+	})
+	return sorted[:min(n, len(sorted))]
+}
+
+// StartIntervals returns the time between consecutive start times, in start
+// time order.
+func (r SfnRecords) StartIntervals() []time.Duration {
+	starts := make([]time.Time, len(r))
+	for i, record := range r {
+		starts[i] = record.StartTime
+	}
+	slices.SortFunc(starts, time.Time.Compare)
+
+	intervals := make([]time.Duration, 0, max(len(starts)-1, 0))
+	for i := 1; i < len(starts); i++ {
+		intervals = append(intervals, starts[i].Sub(starts[i-1]))
+	}
+	return intervals
+}
+
+// Histogram counts the records falling into each bucket delimited by edges.
+// The result has len(edges)+1 buckets: below edges[0], each [edges[i-1],
+// edges[i]), and at or above the last edge.
+func (r SfnRecords) Histogram(edges []time.Duration) []int {
+	counts := make([]int, len(edges)+1)
+	for _, record := range r {
+		counts[sort.Search(len(edges), func(i int) bool { return record.Duration < edges[i] })]++
+	}
+	return counts
+}
+
+// GroupByMonth splits the records by the YYYY-MM of their start time.
+func (r SfnRecords) GroupByMonth() map[string]SfnRecords {
+	months := make(map[string]SfnRecords)
+	for _, record := range r {
+		month := record.StartTime.Format("2006-01")
+		months[month] = append(months[month], record)
+	}
+	return months
+}
+
+// GroupByWeek splits the records by the ISO 8601 week of their start time,
+// formatted as YYYY-Www, e.g. 2026-W03.
+func (r SfnRecords) GroupByWeek() map[string]SfnRecords {
+	weeks := make(map[string]SfnRecords)
+	for _, record := range r {
+		year, week := record.StartTime.ISOWeek()
+		key := fmt.Sprintf("%04d-W%02d", year, week)
+		weeks[key] = append(weeks[key], record)
+	}
+	return weeks
+}
+
+// GroupByStatus splits the records by execution status.
+func (r SfnRecords) GroupByStatus() map[string]SfnRecords {
+	statuses := make(map[string]SfnRecords)
+	for _, record := range r {
+		statuses[record.Status] = append(statuses[record.Status], record)
+	}
+	return statuses
+}
+
+// MapItems returns the total of the map items processed by the records and
+// whether any of them started a map run.
+func (r SfnRecords) MapItems() (int64, bool) {
+	var items int64
+	mapped := false
+	for _, record := range r {
+		items += record.MapItems
+		mapped = mapped || record.MapRuns > 0
+	}
+	return items, mapped
+}
+
+// GroupByRegion splits the records by the region of their state machine.
+func (r SfnRecords) GroupByRegion() map[string]SfnRecords {
+	regions := make(map[string]SfnRecords)
+	for _, record := range r {
+		regions[record.Region] = append(regions[record.Region], record)
+	}
+	return regions
+}
+
+// SuccessRate returns the fraction of records with one of the success
+// statuses, SUCCEEDED when none are given, or 0 when there are no records.
+func (r SfnRecords) SuccessRate(success ...string) float64 {
+	if len(r) == 0 {
+		return 0
+	}
+
+	succeeded := 0
+	for _, record := range r {
+		if isSuccess(record.Status, success) {
+			succeeded++
+		}
+	}
+	return float64(succeeded) / float64(len(r))
+}
+
+// WeightedSuccessRate returns the fraction of the total duration spent in
+// executions with one of the success statuses, SUCCEEDED when none are
+// given, so that a long failure weighs more than a short one. It returns 0
+// when the total duration is zero.
+func (r SfnRecords) WeightedSuccessRate(success ...string) float64 {
+	total := r.TotalDuration()
+	if total == 0 {
+		return 0
+	}
+
+	var succeeded time.Duration
+	for _, record := range r {
+		if isSuccess(record.Status, success) {
+			succeeded += record.Duration
+		}
+	}
+	return float64(succeeded) / float64(total)
+}
+
+func isSuccess(status string, success []string) bool {
+	if len(success) == 0 {
+		return status == sfn.ExecutionStatusSucceeded
+	}
+	return slices.Contains(success, status)
+}
+
+type AggregatedRecordMap map[string]SfnRecords
+
+// Aggregate groups the records by state machine name.
+func (r SfnRecords) Aggregate() AggregatedRecordMap {
+	return r.AggregateBy(func(record SfnRecord) string { return record.Name })
+}
+
+// AggregateBy groups the records by the name key returns for them.
+func (r SfnRecords) AggregateBy(key func(SfnRecord) string) AggregatedRecordMap {
+	aggregated := make(AggregatedRecordMap)
+	for _, record := range r {
+		name := key(record)
+		aggregated[name] = append(aggregated[name], record)
+	}
+	return aggregated
+}
+
+// Names returns the state machine names in lexical order.
+func (m AggregatedRecordMap) Names() []string {
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Filter returns the state machines for which keep returns true.
+func (m AggregatedRecordMap) Filter(keep func(name string, records SfnRecords) bool) AggregatedRecordMap {
+	kept := make(AggregatedRecordMap)
+	for name, records := range m {
+		if keep(name, records) {
+			kept[name] = records
+		}
+	}
+	return kept
+}
+
+// Top keeps the n state machines with the highest metric, breaking ties by name.
+func (m AggregatedRecordMap) Top(n int, metric func(SfnRecords) time.Duration) AggregatedRecordMap {
+	names := m.Names()
+	// Names is already sorted, so a stable sort breaks ties by name.
+	sort.SliceStable(names, func(i, j int) bool { return metric(m[names[i]]) > metric(m[names[j]]) })
+
+	kept := make(AggregatedRecordMap, min(n, len(names)))
+	for _, name := range names[:min(n, len(names))] {
+		kept[name] = m[name]
+	}
+	return kept
+}
@@ -0,0 +1,67 @@
+package measure
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPercentileNanosecondPrecision(t *testing.T) {
+	// Durations one nanosecond apart, given out of order: rounding to
+	// milliseconds anywhere before the output would collapse them.
+	base := 1500 * time.Millisecond
+	records := SfnRecords{
+		{Duration: base + 3},
+		{Duration: base},
+		{Duration: base + 4},
+		{Duration: base + 1},
+		{Duration: base + 2},
+	}
+
+	tests := []struct {
+		p    float64
+		want time.Duration
+	}{
+		{0, base},
+		{25, base + 1},
+		{50, base + 2},
+		{75, base + 3},
+		{100, base + 4},
+		{62.5, base + 3}, // 2.5 rounds half away from zero
+	}
+	for _, tt := range tests {
+		if got := records.Percentile(tt.p); got != tt.want {
+			t.Errorf("Percentile(%v) = %d, want %d", tt.p, got, tt.want)
+		}
+	}
+	if got, want := records.AvgDuration(), base+2; got != want {
+		t.Errorf("AvgDuration() = %d, want %d", got, want)
+	}
+	if got, want := records.MinDuration(), base; got != want {
+		t.Errorf("MinDuration() = %d, want %d", got, want)
+	}
+}
+
+func TestZeroDuration(t *testing.T) {
+	records := SfnRecords{
+		{Duration: 0},
+		{Duration: 2 * time.Second},
+		{Duration: 4 * time.Second},
+	}
+
+	stats := records.Stats()
+	if stats.Count != 3 || stats.Min != 0 || stats.Total != 6*time.Second || stats.Avg != 2*time.Second {
+		t.Errorf("Stats() = %+v, want the zero-duration record counted", stats)
+	}
+
+	nonZero := records.NonZero()
+	if got, want := nonZero.Len(), 2; got != want {
+		t.Fatalf("NonZero().Len() = %d, want %d", got, want)
+	}
+	stats = nonZero.Stats()
+	if stats.Count != 2 || stats.Min != 2*time.Second || stats.Avg != 3*time.Second {
+		t.Errorf("NonZero().Stats() = %+v, want the zero-duration record left out", stats)
+	}
+	if got := records.Len(); got != 3 {
+		t.Errorf("NonZero() changed the receiver to %d records", got)
+	}
+}
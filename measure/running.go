@@ -0,0 +1,122 @@
+package measure
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sfn"
+)
+
+// RunningExecution is an execution in flight at the time of Running.
+type RunningExecution struct {
+	Name          string
+	ExecutionArn  string
+	ExecutionName string
+	StartTime     time.Time
+	Elapsed       time.Duration
+}
+
+// Running lists the running executions of every state machine with
+// StatusFilter RUNNING, so no finished execution is paged through, and
+// returns them with the longest running first. The machine and name prefix
+// filters apply; Since and the other execution filters do not.
+func Running(ctx context.Context, client Client, opts Options) ([]RunningExecution, error) {
+	if opts.Location == nil {
+		opts.Location = time.UTC
+	}
+	if opts.Now == nil {
+		opts.Now = time.Now
+	}
+
+	machines, err := listStateMachines(ctx, client, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &collector{
+		client:  client,
+		opts:    opts,
+		limiter: newLimiter(opts.Concurrency),
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	running := make([][]RunningExecution, len(machines))
+	errs := make([]error, len(machines))
+	var wg sync.WaitGroup
+	for i, machine := range machines {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			running[i], errs[i] = c.runningExecutions(ctx, machine)
+			if errs[i] != nil {
+				cancel()
+			}
+		}()
+	}
+	wg.Wait()
+
+	var result []RunningExecution
+	var firstErr error
+	for i := range machines {
+		result = append(result, running[i]...)
+		if firstErr == nil && errs[i] != nil && !errors.Is(errs[i], context.Canceled) {
+			firstErr = errs[i]
+		}
+	}
+	sort.SliceStable(result, func(i, j int) bool { return result[i].Elapsed > result[j].Elapsed })
+	if firstErr != nil {
+		return result, firstErr
+	}
+	return result, ctx.Err()
+}
+
+func (c *collector) runningExecutions(ctx context.Context, machine *sfn.StateMachineListItem) ([]RunningExecution, error) {
+	name, err := StateMachineName(*machine.StateMachineArn)
+	if err != nil {
+		return nil, err
+	}
+	if !c.opts.wantsMachine(name) {
+		return nil, nil
+	}
+
+	input := &sfn.ListExecutionsInput{
+		StateMachineArn: machine.StateMachineArn,
+		StatusFilter:    aws.String(sfn.ExecutionStatusRunning),
+	}
+	var running []RunningExecution
+	for {
+		if err := c.limiter.acquire(ctx); err != nil {
+			return running, err
+		}
+		page, err := c.client.ListExecutionsWithContext(ctx, input)
+		c.limiter.release()
+		if err != nil {
+			return running, &MachineError{Name: name, StateMachineArn: *machine.StateMachineArn, Err: err}
+		}
+
+		now := c.opts.Now()
+		for _, execution := range page.Executions {
+			if execution.StartDate == nil || !strings.HasPrefix(aws.StringValue(execution.Name), c.opts.ExecutionNamePrefix) {
+				continue
+			}
+			running = append(running, RunningExecution{
+				Name:          name,
+				ExecutionArn:  aws.StringValue(execution.ExecutionArn),
+				ExecutionName: aws.StringValue(execution.Name),
+				StartTime:     execution.StartDate.In(c.opts.Location),
+				Elapsed:       now.Sub(*execution.StartDate),
+			})
+		}
+
+		if page.NextToken == nil {
+			return running, ctx.Err()
+		}
+		input.NextToken = page.NextToken
+	}
+}
@@ -0,0 +1,24 @@
+package measure
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sfn"
+)
+
+// StateMachineTags returns the tags of the state machine as a key-value map.
+func StateMachineTags(ctx context.Context, client Client, stateMachineArn string) (map[string]string, error) {
+	out, err := client.ListTagsForResourceWithContext(ctx, &sfn.ListTagsForResourceInput{
+		ResourceArn: aws.String(stateMachineArn),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	tags := make(map[string]string, len(out.Tags))
+	for _, tag := range out.Tags {
+		tags[aws.StringValue(tag.Key)] = aws.StringValue(tag.Value)
+	}
+	return tags, nil
+}
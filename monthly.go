@@ -0,0 +1,93 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/Finatext/measure-sfn/measure"
+)
+
+// createMonthlyCsvFile writes Count/Total/Avg per state machine per month to
+// monthly.csv. The long layout has one row per (machine, month) pair, the wide
+// layout one row per machine with a column group per month.
+func createMonthlyCsvFile(aggregated measure.AggregatedRecordMap, wide bool) error {
+	w, err := createOutput(outputPath("monthly.csv"))
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+	writer, err := newCsvWriter(w)
+	if err != nil {
+		return err
+	}
+
+	byName := make(map[string]map[string]measure.SfnRecords, len(aggregated))
+	monthSet := make(map[string]struct{})
+	for name, records := range aggregated {
+		byName[name] = records.GroupByMonth()
+		for month := range byName[name] {
+			monthSet[month] = struct{}{}
+		}
+	}
+	names := aggregated.Names()
+
+	months := make([]string, 0, len(monthSet))
+	for month := range monthSet {
+		months = append(months, month)
+	}
+	sort.Strings(months)
+
+	if wide {
+		header := []string{"Name"}
+		for _, month := range months {
+			header = append(header, month+" Count", month+" "+durationHeader("Total"), month+" "+durationHeader("Avg"))
+		}
+		if err := writer.Write(header); err != nil {
+			return err
+		}
+
+		for _, name := range names {
+			row := []string{name}
+			for _, month := range months {
+				records, ok := byName[name][month]
+				if !ok {
+					row = append(row, "0", "", "")
+					continue
+				}
+				row = append(row,
+					fmt.Sprintf("%d", records.Len()),
+					formatDuration(records.TotalDuration()),
+					formatDuration(records.AvgDuration()),
+				)
+			}
+			if err := writer.Write(row); err != nil {
+				return err
+			}
+		}
+	} else {
+		if err := writer.Write([]string{"Name", "Month", "Count", durationHeader("Total"), durationHeader("Avg")}); err != nil {
+			return err
+		}
+
+		for _, name := range names {
+			for _, month := range months {
+				records, ok := byName[name][month]
+				if !ok {
+					continue
+				}
+				if err := writer.Write([]string{
+					name,
+					month,
+					fmt.Sprintf("%d", records.Len()),
+					formatDuration(records.TotalDuration()),
+					formatDuration(records.AvgDuration()),
+				}); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
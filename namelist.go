@@ -0,0 +1,33 @@
+package main
+
+import (
+	"bufio"
+	"os"
+	"strings"
+)
+
+// readNameList reads a newline-delimited list of state machine names. Blank
+// lines and lines starting with # are ignored so that the lists can be
+// commented in version control. An empty path yields no names.
+func readNameList(path string) ([]string, error) {
+	if path == "" {
+		return nil, nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var names []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		names = append(names, line)
+	}
+	return names, scanner.Err()
+}
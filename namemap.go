@@ -0,0 +1,64 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// nameRule rewrites the state machine names matching pattern with
+// replacement, which may refer to submatches as in regexp.ReplaceAllString.
+type nameRule struct {
+	pattern     *regexp.Regexp
+	replacement string
+}
+
+// nameMap is set by --name-map and normalizes the state machine names the
+// aggregate outputs group by, e.g. to roll per-environment state machines up
+// to one logical name. The records file keeps the original names.
+var nameMap []nameRule
+
+// readNameMap reads one rule per line: a regular expression, then after
+// whitespace an optional replacement, which defaults to removing the match.
+// Blank lines and lines starting with # are ignored as in readNameList. An
+// empty path yields no rules.
+func readNameMap(path string) ([]nameRule, error) {
+	if path == "" {
+		return nil, nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var rules []nameRule
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		expr, replacement := line, ""
+		if i := strings.IndexAny(line, " \t"); i >= 0 {
+			expr, replacement = line[:i], strings.TrimSpace(line[i:])
+		}
+		pattern, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, n, err)
+		}
+		rules = append(rules, nameRule{pattern, replacement})
+	}
+	return rules, scanner.Err()
+}
+
+// normalizeName applies every rule of nameMap to name in turn.
+func normalizeName(name string) string {
+	for _, rule := range nameMap {
+		name = rule.pattern.ReplaceAllString(name, rule.replacement)
+	}
+	return name
+}
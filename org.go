@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/arn"
+	"github.com/aws/aws-sdk-go/aws/credentials/stscreds"
+	"github.com/aws/aws-sdk-go/service/organizations"
+	"github.com/aws/aws-sdk-go/service/sfn"
+)
+
+// createOrgTargets builds a client per region for every active account of the
+// organization, listed with the credentials of c, by assuming roleName in each
+// account. The roles are assumed lazily, so an account whose role cannot be
+// assumed only fails its own targets when they are collected.
+func createOrgTargets(ctx context.Context, c sessionConfig, regions []string, roleName string) ([]target, error) {
+	c.Region = regions[0]
+	sess, creds, err := newSession(ctx, c)
+	if err != nil {
+		return nil, err
+	}
+	// base signs the AssumeRole calls with the credentials of c, which may be
+	// assumed themselves with --role-arn.
+	base := sess.Copy(aws.NewConfig().WithCredentials(creds))
+
+	var accounts []*organizations.Account
+	err = organizations.New(base).ListAccountsPagesWithContext(ctx, &organizations.ListAccountsInput{}, func(page *organizations.ListAccountsOutput, _ bool) bool {
+		for _, account := range page.Accounts {
+			if aws.StringValue(account.Status) == organizations.AccountStatusActive {
+				accounts = append(accounts, account)
+			}
+		}
+		return ctx.Err() == nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("listing the organization accounts: %w", err)
+	}
+
+	var targets []target
+	for _, account := range accounts {
+		id := aws.StringValue(account.Id)
+		partition := "aws"
+		if a, err := arn.Parse(aws.StringValue(account.Arn)); err == nil {
+			partition = a.Partition
+		}
+		roleArn := fmt.Sprintf("arn:%s:iam::%s:role/%s", partition, id, roleName)
+		accountCreds := stscreds.NewCredentials(base, roleArn, func(p *stscreds.AssumeRoleProvider) {
+			p.Duration = c.assumeRoleDuration()
+			p.ExpiryWindow = assumeRoleExpiryWindow
+		})
+
+		for _, region := range regions {
+			cfg := aws.NewConfig().WithCredentials(accountCreds)
+			if region != "" {
+				cfg = cfg.WithRegion(region)
+			}
+			targets = append(targets, target{
+				profile:     c.Profile,
+				region:      region,
+				account:     id,
+				accountName: aws.StringValue(account.Name),
+				client:      sfn.New(sess, cfg),
+			})
+		}
+	}
+	warnf("--org: measuring %d accounts in %d regions", len(accounts), len(regions))
+	return targets, nil
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/Finatext/measure-sfn/measure"
+)
+
+// The OTLP/HTTP JSON encoding of ExportMetricsServiceRequest, limited to the
+// gauges and sums written here. 64-bit integers are strings as in the
+// protobuf JSON mapping.
+type otlpRequest struct {
+	ResourceMetrics []otlpResourceMetrics `json:"resourceMetrics"`
+}
+
+type otlpResourceMetrics struct {
+	Resource     otlpResource       `json:"resource"`
+	ScopeMetrics []otlpScopeMetrics `json:"scopeMetrics"`
+}
+
+type otlpResource struct {
+	Attributes []otlpAttribute `json:"attributes"`
+}
+
+type otlpScopeMetrics struct {
+	Scope   otlpScope    `json:"scope"`
+	Metrics []otlpMetric `json:"metrics"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpMetric struct {
+	Name  string     `json:"name"`
+	Unit  string     `json:"unit,omitempty"`
+	Gauge *otlpGauge `json:"gauge,omitempty"`
+	Sum   *otlpSum   `json:"sum,omitempty"`
+}
+
+type otlpGauge struct {
+	DataPoints []otlpDataPoint `json:"dataPoints"`
+}
+
+type otlpSum struct {
+	DataPoints []otlpDataPoint `json:"dataPoints"`
+	// AggregationTemporality 1 is DELTA: the count over the window.
+	AggregationTemporality int  `json:"aggregationTemporality"`
+	IsMonotonic            bool `json:"isMonotonic"`
+}
+
+type otlpDataPoint struct {
+	Attributes        []otlpAttribute `json:"attributes"`
+	StartTimeUnixNano string          `json:"startTimeUnixNano,omitempty"`
+	TimeUnixNano      string          `json:"timeUnixNano"`
+	AsDouble          *float64        `json:"asDouble,omitempty"`
+	AsInt             string          `json:"asInt,omitempty"`
+}
+
+type otlpAttribute struct {
+	Key   string       `json:"key"`
+	Value otlpAnyValue `json:"value"`
+}
+
+type otlpAnyValue struct {
+	StringValue string `json:"stringValue"`
+}
+
+func otlpString(key, value string) otlpAttribute {
+	return otlpAttribute{Key: key, Value: otlpAnyValue{StringValue: value}}
+}
+
+// otlpMetrics builds the request of the aggregate: sfn.execution.duration.avg,
+// .p95 and .max gauges in seconds and an sfn.executions sum per status, all
+// with a state_machine attribute.
+func otlpMetrics(aggregated measure.AggregatedRecordMap) otlpRequest {
+	stop := now()
+	timestamp := strconv.FormatInt(stop.UnixNano(), 10)
+	start := strconv.FormatInt(stop.Add(-window).UnixNano(), 10)
+
+	gauges := []struct {
+		name  string
+		value func(measure.SfnRecords) float64
+	}{
+		{"sfn.execution.duration.avg", func(r measure.SfnRecords) float64 { return r.AvgDuration().Seconds() }},
+		{"sfn.execution.duration.p95", func(r measure.SfnRecords) float64 { return r.Percentile(95).Seconds() }},
+		{"sfn.execution.duration.max", func(r measure.SfnRecords) float64 { return r.MaxDuration().Seconds() }},
+	}
+
+	var metrics []otlpMetric
+	for _, g := range gauges {
+		gauge := &otlpGauge{}
+		for _, name := range aggregated.Names() {
+			value := g.value(aggregated[name])
+			gauge.DataPoints = append(gauge.DataPoints, otlpDataPoint{
+				Attributes:   []otlpAttribute{otlpString("state_machine", name)},
+				TimeUnixNano: timestamp,
+				AsDouble:     &value,
+			})
+		}
+		metrics = append(metrics, otlpMetric{Name: g.name, Unit: "s", Gauge: gauge})
+	}
+
+	sum := &otlpSum{AggregationTemporality: 1, IsMonotonic: true}
+	for _, name := range aggregated.Names() {
+		byStatus := aggregated[name].GroupByStatus()
+		for _, status := range sortedStatuses(byStatus) {
+			sum.DataPoints = append(sum.DataPoints, otlpDataPoint{
+				Attributes:        []otlpAttribute{otlpString("state_machine", name), otlpString("status", status)},
+				StartTimeUnixNano: start,
+				TimeUnixNano:      timestamp,
+				AsInt:             strconv.Itoa(byStatus[status].Len()),
+			})
+		}
+	}
+	metrics = append(metrics, otlpMetric{Name: "sfn.executions", Unit: "{execution}", Sum: sum})
+
+	return otlpRequest{ResourceMetrics: []otlpResourceMetrics{{
+		Resource:     otlpResource{Attributes: []otlpAttribute{otlpString("service.name", "measure-sfn")}},
+		ScopeMetrics: []otlpScopeMetrics{{Scope: otlpScope{Name: "measure-sfn"}, Metrics: metrics}},
+	}}}
+}
+
+// exportOTLP posts the aggregate to an OTLP/HTTP collector with the JSON
+// encoding. An endpoint without a path gets the default /v1/metrics. The
+// export, including reading the response, is bounded by --otlp-timeout so
+// that a dead collector cannot hang the run.
+func exportOTLP(ctx context.Context, endpoint string, aggregated measure.AggregatedRecordMap) error {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return fmt.Errorf("invalid --otlp-endpoint: %w", err)
+	}
+	if u.Path == "" || u.Path == "/" {
+		u.Path = "/v1/metrics"
+	}
+
+	body, err := json.Marshal(otlpMetrics(aggregated))
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, *otlpTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("exporting to OTLP: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("exporting to OTLP: %s: %s", resp.Status, bytes.TrimSpace(msg))
+	}
+	return nil
+}
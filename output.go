@@ -0,0 +1,43 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+)
+
+// outputDir is set by --output-dir. Output files are written to the working
+// directory when it is empty.
+var outputDir string
+
+// outputPath resolves the path of an output file against outputDir. Absolute
+// paths are kept as given.
+func outputPath(path string) string {
+	return resolveAgainst(outputDir, path)
+}
+
+func resolveAgainst(dir, path string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(dir, path)
+}
+
+// createdOutputs lists the regular files createOutput created or truncated,
+// for --manifest.
+var createdOutputs []string
+
+// createOutput opens an output file for writing. Regular files are created or
+// truncated as with os.Create, but an existing FIFO or device such as
+// /dev/stdout is opened for writing as is: truncating it is meaningless at
+// best and creating it must not be attempted.
+func createOutput(path string) (*os.File, error) {
+	if info, err := os.Stat(path); err == nil && !info.Mode().IsRegular() && !info.IsDir() {
+		return os.OpenFile(path, os.O_WRONLY, 0)
+	}
+	f, err := os.Create(path)
+	if err == nil && !slices.Contains(createdOutputs, path) {
+		createdOutputs = append(createdOutputs, path)
+	}
+	return f, err
+}
@@ -0,0 +1,285 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"math"
+	"time"
+
+	"github.com/Finatext/measure-sfn/measure"
+)
+
+// createParquetFile writes the records to path as a single row group
+// with uncompressed, PLAIN encoded, required columns. The schema is stable and
+// independent of --unit:
+//
+//	name             BYTE_ARRAY (STRING)
+//	start_date       INT32 (DATE, days since the Unix epoch)
+//	duration_seconds DOUBLE
+//	status           BYTE_ARRAY (STRING)
+func createParquetFile(path string, records measure.SfnRecords) error {
+	w, err := createOutput(path)
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+
+	bw := bufio.NewWriter(w)
+	if err := writeParquet(bw, records); err != nil {
+		return err
+	}
+	if err := bw.Flush(); err != nil {
+		return err
+	}
+	return w.Close()
+}
+
+// Parquet physical types, converted types and enums from parquet.thrift.
+const (
+	parquetInt32     = 1
+	parquetDouble    = 5
+	parquetByteArray = 6
+
+	parquetConvertedUTF8 = 0
+	parquetConvertedDate = 6
+
+	parquetRequired     = 0
+	parquetPlain        = 0
+	parquetRLE          = 3
+	parquetUncompressed = 0
+	parquetDataPage     = 0
+)
+
+type parquetColumn struct {
+	name      string
+	typ       int32
+	converted int32
+	// logicalType is the field ID of the LogicalType union member.
+	logicalType int16
+	encode      func(buf *bytes.Buffer, r measure.SfnRecord)
+}
+
+var parquetColumns = []parquetColumn{
+	{"name", parquetByteArray, parquetConvertedUTF8, 1, func(buf *bytes.Buffer, r measure.SfnRecord) { writePlainString(buf, r.Name) }},
+	{"start_date", parquetInt32, parquetConvertedDate, 6, func(buf *bytes.Buffer, r measure.SfnRecord) {
+		// The date is the calendar date of StartDate, so count days from the
+		// epoch on that date rather than on the instant.
+		date, _ := time.Parse(time.DateOnly, r.StartDate)
+		binary.Write(buf, binary.LittleEndian, int32(date.Unix()/86400))
+	}},
+	{"duration_seconds", parquetDouble, -1, 0, func(buf *bytes.Buffer, r measure.SfnRecord) {
+		binary.Write(buf, binary.LittleEndian, math.Float64bits(r.Duration.Seconds()))
+	}},
+	{"status", parquetByteArray, parquetConvertedUTF8, 1, func(buf *bytes.Buffer, r measure.SfnRecord) { writePlainString(buf, r.Status) }},
+}
+
+func writePlainString(buf *bytes.Buffer, s string) {
+	binary.Write(buf, binary.LittleEndian, uint32(len(s)))
+	buf.WriteString(s)
+}
+
+func writeParquet(w *bufio.Writer, records measure.SfnRecords) error {
+	const magic = "PAR1"
+
+	var file bytes.Buffer
+	file.WriteString(magic)
+
+	type chunk struct {
+		offset int64
+		size   int64
+	}
+	chunks := make([]chunk, len(parquetColumns))
+
+	for i, column := range parquetColumns {
+		var data bytes.Buffer
+		for _, record := range records {
+			column.encode(&data, record)
+		}
+
+		// PageHeader
+		header := newThriftWriter()
+		header.i32(1, parquetDataPage)
+		header.i32(2, int32(data.Len()))
+		header.i32(3, int32(data.Len()))
+		header.beginStruct(5) // DataPageHeader
+		header.i32(1, int32(len(records)))
+		header.i32(2, parquetPlain)
+		header.i32(3, parquetRLE)
+		header.i32(4, parquetRLE)
+		header.endStruct()
+		header.stop()
+
+		chunks[i] = chunk{offset: int64(file.Len()), size: int64(header.buf.Len() + data.Len())}
+		file.Write(header.buf.Bytes())
+		file.Write(data.Bytes())
+	}
+
+	// FileMetaData
+	meta := newThriftWriter()
+	meta.i32(1, 1)
+	meta.beginList(2, thriftStruct, len(parquetColumns)+1)
+	meta.beginElem()
+	meta.binary(4, "schema")
+	meta.i32(5, int32(len(parquetColumns)))
+	meta.endElem()
+	for _, column := range parquetColumns {
+		meta.beginElem()
+		meta.i32(1, column.typ)
+		meta.i32(3, parquetRequired)
+		meta.binary(4, column.name)
+		if column.converted >= 0 {
+			meta.i32(6, column.converted)
+		}
+		if column.logicalType > 0 {
+			meta.beginStruct(10)
+			meta.beginStruct(column.logicalType)
+			meta.endStruct()
+			meta.endStruct()
+		}
+		meta.endElem()
+	}
+	meta.i64(3, int64(len(records)))
+
+	var total int64
+	for _, c := range chunks {
+		total += c.size
+	}
+	meta.beginList(4, thriftStruct, 1)
+	meta.beginElem() // RowGroup
+	meta.beginList(1, thriftStruct, len(parquetColumns))
+	for i, column := range parquetColumns {
+		meta.beginElem() // ColumnChunk
+		meta.i64(2, chunks[i].offset)
+		meta.beginStruct(3) // ColumnMetaData
+		meta.i32(1, column.typ)
+		meta.beginList(2, thriftI32, 2)
+		meta.i32Elem(parquetPlain)
+		meta.i32Elem(parquetRLE)
+		meta.beginList(3, thriftBinary, 1)
+		meta.binaryElem(column.name)
+		meta.i32(4, parquetUncompressed)
+		meta.i64(5, int64(len(records)))
+		meta.i64(6, chunks[i].size)
+		meta.i64(7, chunks[i].size)
+		meta.i64(9, chunks[i].offset)
+		meta.endStruct()
+		meta.endElem()
+	}
+	meta.i64(2, total)
+	meta.i64(3, int64(len(records)))
+	meta.endElem()
+	if runID != "" {
+		meta.beginList(5, thriftStruct, 1)
+		meta.beginElem() // KeyValue
+		meta.binary(1, "run_id")
+		meta.binary(2, runID)
+		meta.endElem()
+	}
+	meta.binary(6, "measure-sfn")
+	meta.stop()
+
+	file.Write(meta.buf.Bytes())
+	binary.Write(&file, binary.LittleEndian, uint32(meta.buf.Len()))
+	file.WriteString(magic)
+
+	_, err := w.Write(file.Bytes())
+	return err
+}
+
+// Thrift compact protocol type IDs.
+const (
+	thriftI32    = 5
+	thriftI64    = 6
+	thriftBinary = 8
+	thriftList   = 9
+	thriftStruct = 12
+)
+
+// thriftWriter encodes structs with the Thrift compact protocol, which is all
+// the Parquet metadata needs. Fields must be written in increasing ID order.
+type thriftWriter struct {
+	buf bytes.Buffer
+	// lastID is a stack of the last field ID written in each open struct.
+	lastID []int16
+}
+
+func newThriftWriter() *thriftWriter {
+	return &thriftWriter{lastID: []int16{0}}
+}
+
+func (t *thriftWriter) fieldHeader(id int16, typ byte) {
+	last := &t.lastID[len(t.lastID)-1]
+	if delta := id - *last; delta > 0 && delta <= 15 {
+		t.buf.WriteByte(byte(delta)<<4 | typ)
+	} else {
+		t.buf.WriteByte(typ)
+		t.varint(uint64(zigzag(int64(id))))
+	}
+	*last = id
+}
+
+func (t *thriftWriter) varint(v uint64) {
+	t.buf.Write(binary.AppendUvarint(nil, v))
+}
+
+func zigzag(v int64) uint64 {
+	return uint64((v << 1) ^ (v >> 63))
+}
+
+func (t *thriftWriter) i32(id int16, v int32) {
+	t.fieldHeader(id, thriftI32)
+	t.varint(zigzag(int64(v)))
+}
+
+func (t *thriftWriter) i64(id int16, v int64) {
+	t.fieldHeader(id, thriftI64)
+	t.varint(zigzag(v))
+}
+
+func (t *thriftWriter) binary(id int16, s string) {
+	t.fieldHeader(id, thriftBinary)
+	t.binaryElem(s)
+}
+
+func (t *thriftWriter) beginStruct(id int16) {
+	t.fieldHeader(id, thriftStruct)
+	t.lastID = append(t.lastID, 0)
+}
+
+func (t *thriftWriter) endStruct() {
+	t.stop()
+	t.lastID = t.lastID[:len(t.lastID)-1]
+}
+
+func (t *thriftWriter) stop() {
+	t.buf.WriteByte(0)
+}
+
+func (t *thriftWriter) beginList(id int16, elemType byte, size int) {
+	t.fieldHeader(id, thriftList)
+	if size < 15 {
+		t.buf.WriteByte(byte(size)<<4 | elemType)
+	} else {
+		t.buf.WriteByte(0xf0 | elemType)
+		t.varint(uint64(size))
+	}
+}
+
+// beginElem and endElem delimit a struct element of a list.
+func (t *thriftWriter) beginElem() {
+	t.lastID = append(t.lastID, 0)
+}
+
+func (t *thriftWriter) endElem() {
+	t.endStruct()
+}
+
+func (t *thriftWriter) i32Elem(v int32) {
+	t.varint(zigzag(int64(v)))
+}
+
+func (t *thriftWriter) binaryElem(s string) {
+	t.varint(uint64(len(s)))
+	t.buf.WriteString(s)
+}
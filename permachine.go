@@ -0,0 +1,76 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/Finatext/measure-sfn/measure"
+)
+
+// sanitizeFileName replaces every character that is not safe in a file name
+// on common file systems with an underscore.
+func sanitizeFileName(name string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_', r == '.':
+			return r
+		}
+		return '_'
+	}, name)
+}
+
+// createPerMachineCsvFiles writes the records of each state machine to its own
+// CSV file in dir, creating dir if needed.
+func createPerMachineCsvFiles(dir string, aggregated measure.AggregatedRecordMap, columns []recordColumn) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+
+	names := aggregated.Names()
+	fileNames := perMachineFileNames(names)
+	for _, name := range names {
+		path := filepath.Join(dir, fileNames[name]+".csv")
+		if err := createCsvFile(path, aggregated[name], columns); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// perMachineFileNames maps each name to its sanitized file name. Names that
+// sanitize to the same file name, ignoring case for case-insensitive file
+// systems, e.g. a:b and a/b or Etl and ETL, all get a short hash of the name
+// appended so that none overwrites another and each keeps its file name from
+// run to run.
+func perMachineFileNames(names []string) map[string]string {
+	fileNames := make(map[string]string, len(names))
+	taken := make(map[string]int, len(names))
+	for _, name := range names {
+		fileNames[name] = sanitizeFileName(name)
+		taken[strings.ToLower(fileNames[name])]++
+	}
+	for _, name := range names {
+		if taken[strings.ToLower(fileNames[name])] > 1 {
+			sum := sha256.Sum256([]byte(name))
+			fileNames[name] += "-" + hex.EncodeToString(sum[:4])
+		}
+	}
+	return fileNames
+}
+
+// createPerStatusCsvFiles writes the records of each observed status to its
+// own sfn-<status>.csv next to the combined records file, e.g.
+// sfn-succeeded.csv.
+func createPerStatusCsvFiles(records measure.SfnRecords, columns []recordColumn) error {
+	byStatus := records.GroupByStatus()
+	for _, status := range sortedStatuses(byStatus) {
+		path := outputPath("sfn-" + sanitizeFileName(strings.ToLower(status)) + ".csv")
+		if err := createCsvFile(path, byStatus[status], columns); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+)
+
+// postCommandPath returns the output handed to --post-command: the records
+// file of the first --format, sfn.ndjson with --stream, or aggregate.csv with
+// --only-aggregate.
+func postCommandPath() string {
+	switch {
+	case *stream:
+		return outputPath("sfn.ndjson")
+	case *onlyAggr:
+		return outputPath("aggregate.csv")
+	}
+	return recordsPath(outputFormats[0])
+}
+
+// runPostCommand runs --post-command with sh once the outputs are written,
+// passing the path of the output as $1 and its content on stdin, e.g.
+// 'jq -c . "$1" | my-uploader'. A failing command only warns, unless
+// --post-required is set.
+func runPostCommand(ctx context.Context, command string) error {
+	path := postCommandPath()
+	cmd := exec.CommandContext(ctx, "sh", "-c", command, "measure-sfn", path)
+	cmd.Stdout, cmd.Stderr = os.Stdout, os.Stderr
+	// A records file written to a pipe or a device cannot be read back.
+	if info, err := os.Stat(path); err == nil && info.Mode().IsRegular() {
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		cmd.Stdin = f
+	}
+
+	err := cmd.Run()
+	if err == nil {
+		return nil
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		err = fmt.Errorf("--post-command exited with status %d", exitErr.ExitCode())
+	} else {
+		err = fmt.Errorf("--post-command: %w", err)
+	}
+	if *postRequired {
+		return err
+	}
+	warnf("%v", err)
+	return nil
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// sharedConfigFiles returns the shared config and credentials file paths,
+// defaulting the empty ones with the same environment variables as the SDK.
+func sharedConfigFiles(config, credentials string) (string, string) {
+	home, _ := os.UserHomeDir()
+
+	if config == "" {
+		config = os.Getenv("AWS_CONFIG_FILE")
+	}
+	if config == "" {
+		config = filepath.Join(home, ".aws", "config")
+	}
+	if credentials == "" {
+		credentials = os.Getenv("AWS_SHARED_CREDENTIALS_FILE")
+	}
+	if credentials == "" {
+		credentials = filepath.Join(home, ".aws", "credentials")
+	}
+	return config, credentials
+}
+
+// listProfiles returns the sorted, de-duplicated profile names defined in the
+// shared config and credentials files, --config-file and --credentials-file
+// when set. Missing files are ignored.
+func listProfiles() ([]string, error) {
+	config, credentials := sharedConfigFiles(*configFile, *credsFile)
+
+	set := make(map[string]struct{})
+	for _, f := range []struct {
+		path string
+		// prefixed is true for the config file, where profiles other than
+		// default are written as [profile name].
+		prefixed bool
+	}{{config, true}, {credentials, false}} {
+		names, err := readProfileSections(f.path, f.prefixed)
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range names {
+			set[name] = struct{}{}
+		}
+	}
+
+	names := make([]string, 0, len(set))
+	for name := range set {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func readProfileSections(path string, prefixed bool) ([]string, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var names []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if !strings.HasPrefix(line, "[") || !strings.HasSuffix(line, "]") {
+			continue
+		}
+		section := strings.TrimSpace(line[1 : len(line)-1])
+
+		if !prefixed || section == "default" {
+			names = append(names, section)
+			continue
+		}
+		// Skip other section kinds such as [sso-session name] or [services name].
+		if name, ok := strings.CutPrefix(section, "profile "); ok {
+			names = append(names, strings.TrimSpace(name))
+		}
+	}
+	return names, scanner.Err()
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/sfn"
+)
+
+// limitRate gates every request made by svc on a token bucket refilled at
+// perSecond calls per second. The wait happens before signing each attempt, so
+// SDK retries are throttled as well. The bucket is shared by everything using
+// svc, so raising concurrency does not raise the call rate above the limit.
+func limitRate(svc *sfn.SFN, perSecond float64) {
+	limiter := &rateLimiter{interval: time.Duration(float64(time.Second) / perSecond)}
+	svc.Handlers.Sign.PushFront(func(r *request.Request) {
+		if err := limiter.wait(r.Context()); err != nil {
+			r.Error = err
+		}
+	})
+}
+
+// rateLimiter is a token bucket holding a single token, refilled every
+// interval: each call is let through at least interval after the previous one.
+type rateLimiter struct {
+	interval time.Duration
+
+	mu   sync.Mutex
+	next time.Time
+}
+
+// wait blocks until the caller's turn or until ctx is done. A cancelled
+// caller gives its turn back only when no later caller has reserved one.
+func (l *rateLimiter) wait(ctx context.Context) error {
+	l.mu.Lock()
+	now := time.Now()
+	at := l.next
+	if at.Before(now) {
+		at = now
+	}
+	l.next = at.Add(l.interval)
+	l.mu.Unlock()
+
+	delay := time.Until(at)
+	if delay <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		l.mu.Lock()
+		if l.next.Equal(at.Add(l.interval)) {
+			l.next = at
+		}
+		l.mu.Unlock()
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/Finatext/measure-sfn/measure"
+)
+
+// recordFormats lists the --format values, which double as the file
+// extensions they are detected from.
+var recordFormats = []string{"csv", "tsv", "json", "ndjson", "md", "html", "parquet"}
+
+// outputFormats holds the formats of the records file resolved from --format.
+var outputFormats []string
+
+// resolveFormats parses the comma-separated --format value. "auto" picks the
+// format matching the extension of out, falling back to csv for a missing or
+// unknown extension, so that --out report.json needs no --format.
+func resolveFormats(format, out string) ([]string, error) {
+	if format == "auto" {
+		if ext := strings.ToLower(strings.TrimPrefix(filepath.Ext(out), ".")); slices.Contains(recordFormats, ext) {
+			return []string{ext}, nil
+		}
+		return []string{"csv"}, nil
+	}
+
+	var formats []string
+	for _, f := range splitList(format) {
+		if !slices.Contains(recordFormats, f) {
+			return nil, fmt.Errorf("unknown --format: %s", f)
+		}
+		if !slices.Contains(formats, f) {
+			formats = append(formats, f)
+		}
+	}
+	return formats, nil
+}
+
+// recordsPath returns the path of the records file in format: sfn.<format>,
+// or --out. With several formats the extension of --out is replaced by each
+// format in turn.
+func recordsPath(format string) string {
+	if *out == "" {
+		return outputPath("sfn." + format)
+	}
+	if len(outputFormats) == 1 {
+		return outputPath(*out)
+	}
+	return outputPath(strings.TrimSuffix(*out, filepath.Ext(*out)) + "." + format)
+}
+
+// createRecordsFile writes the records to path in format. Parquet has a fixed
+// schema and ignores columns.
+func createRecordsFile(path, format string, records measure.SfnRecords, columns []recordColumn) error {
+	switch format {
+	case "tsv":
+		return createDelimitedFile(path, records, columns, '\t')
+	case "json":
+		return createJSONFile(path, records, columns, *withSchema, *compact)
+	case "ndjson":
+		return createNDJSONFile(path, records, columns)
+	case "md":
+		return createMarkdownFile(path, records, columns)
+	case "html":
+		return createHTMLFile(path, records, columns)
+	case "parquet":
+		return createParquetFile(path, records)
+	}
+	return createCsvFile(path, records, columns)
+}
+
+// createNDJSONFile writes the records to path as one JSON object per line,
+// encoded as in the json format.
+func createNDJSONFile(path string, records measure.SfnRecords, columns []recordColumn) error {
+	w, err := createOutput(path)
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+	bw := bufio.NewWriter(w)
+
+	for _, record := range records {
+		line, err := json.Marshal(jsonRecord{record: record, columns: columns})
+		if err != nil {
+			return err
+		}
+		bw.Write(line)
+		if err := bw.WriteByte('\n'); err != nil {
+			return err
+		}
+	}
+
+	if err := bw.Flush(); err != nil {
+		return err
+	}
+	return w.Close()
+}
+
+// displayValue renders a column value for the human-oriented formats, grouping
+// the digits of numbers when --thousands-sep is set.
+func displayValue(column recordColumn, record measure.SfnRecord) string {
+	if column.Type == columnString {
+		return column.Value(record)
+	}
+	return humanNumber(column.Value(record))
+}
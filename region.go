@@ -0,0 +1,43 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/Finatext/measure-sfn/measure"
+)
+
+// createRegionCsvFile writes by-region.csv with the executions of every state
+// machine rolled up per region.
+func createRegionCsvFile(records measure.SfnRecords) error {
+	w, err := createOutput(outputPath("by-region.csv"))
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+	writer, err := newCsvWriter(w)
+	if err != nil {
+		return err
+	}
+
+	if err := writer.Write([]string{"Region", "Count", durationHeader("Avg"), durationHeader("P95"), "SuccessRate"}); err != nil {
+		return err
+	}
+
+	byRegion := measure.AggregatedRecordMap(records.GroupByRegion())
+	for _, region := range byRegion.Names() {
+		r := byRegion[region]
+		if err := writer.Write([]string{
+			region,
+			strconv.Itoa(r.Len()),
+			formatDuration(r.AvgDuration()),
+			formatDuration(r.Percentile(95)),
+			fmt.Sprintf("%.2f", r.SuccessRate(successStatuses...)),
+		}); err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
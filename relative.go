@@ -0,0 +1,30 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/Finatext/measure-sfn/measure"
+)
+
+// machineMedians holds the median duration per state machine name, prepared
+// by prepareMedians for the PctOfMedian column of --relative.
+var machineMedians map[string]time.Duration
+
+func prepareMedians(records measure.SfnRecords) {
+	machineMedians = make(map[string]time.Duration)
+	for name, r := range records.Aggregate() {
+		machineMedians[name] = r.Percentile(50)
+	}
+}
+
+// pctOfMedian formats the duration of record as a percentage of the median of
+// its state machine, e.g. 300.0 for three times the usual duration. It is
+// empty when the median is zero.
+func pctOfMedian(record measure.SfnRecord) string {
+	median := machineMedians[record.Name]
+	if median == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%.1f", 100*float64(record.Duration)/float64(median))
+}
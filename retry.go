@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/client"
+	"github.com/aws/aws-sdk-go/aws/request"
+)
+
+// Retries happen in a single layer: the SDK retryer configured here retries
+// each API call attempt on throttling and transient errors with exponential
+// backoff. --rate-limit is applied before every attempt, retries included, so
+// it bounds the call rate regardless of how many retries happen.
+//
+// By default the SDK retries 5xx responses other than 501, connection errors
+// and timeouts, expired credentials, the RequestError, RequestTimeout,
+// ResponseTimeout and RequestTimeoutException codes, and throttling codes
+// such as Throttling, ThrottlingException, TooManyRequestsException and
+// RequestLimitExceeded. --retry-on adds error codes to that set.
+//
+// aws-sdk-go v1 does not read AWS_MAX_ATTEMPTS or AWS_RETRY_MODE itself, so
+// they are honored here as defaults for --max-attempts and --retry-mode.
+
+// defaultMaxAttempts returns AWS_MAX_ATTEMPTS, or 0 to keep the SDK default.
+func defaultMaxAttempts() int {
+	n, err := strconv.Atoi(os.Getenv("AWS_MAX_ATTEMPTS"))
+	if err != nil || n < 0 {
+		return 0
+	}
+	return n
+}
+
+func defaultRetryMode() string {
+	return os.Getenv("AWS_RETRY_MODE")
+}
+
+// newRetryer builds the SDK retryer for the given mode and total number of
+// attempts (the first call plus retries), additionally retrying the error
+// codes of retryOn. It returns nil to keep the SDK's own retryer when none is
+// set.
+//
+// "standard" uses the SDK's exponential backoff. aws-sdk-go v1 has no
+// client-side rate limiting, so "adaptive" approximates it by backing off much
+// longer after throttling errors.
+func newRetryer(mode string, maxAttempts int, retryOn []string) (request.Retryer, error) {
+	if mode == "" && maxAttempts == 0 && len(retryOn) == 0 {
+		return nil, nil
+	}
+
+	retryer, err := newDefaultRetryer(mode, maxAttempts)
+	if err != nil {
+		return nil, err
+	}
+	if len(retryOn) > 0 {
+		return codeRetryer{DefaultRetryer: retryer, codes: retryOn}, nil
+	}
+	return retryer, nil
+}
+
+func newDefaultRetryer(mode string, maxAttempts int) (client.DefaultRetryer, error) {
+	retries := client.DefaultRetryerMaxNumRetries
+	if maxAttempts > 0 {
+		retries = maxAttempts - 1
+	}
+
+	switch mode {
+	case "", "standard", "legacy":
+		return client.DefaultRetryer{NumMaxRetries: retries}, nil
+	case "adaptive":
+		return client.DefaultRetryer{
+			NumMaxRetries:    retries,
+			MinThrottleDelay: time.Second,
+			MaxThrottleDelay: 20 * time.Second,
+		}, nil
+	}
+	return client.DefaultRetryer{}, fmt.Errorf("unknown retry mode: %s", mode)
+}
+
+// codeRetryer also retries the errors with one of codes, with the same
+// backoff as the default retryable errors.
+type codeRetryer struct {
+	client.DefaultRetryer
+	codes []string
+}
+
+func (r codeRetryer) ShouldRetry(req *request.Request) bool {
+	if aerr, ok := req.Error.(awserr.Error); ok && slices.Contains(r.codes, aerr.Code()) {
+		return true
+	}
+	return r.DefaultRetryer.ShouldRetry(req)
+}
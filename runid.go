@@ -0,0 +1,49 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/csv"
+	"fmt"
+	"io"
+)
+
+// runID is set by --run-id and tags every output file of the invocation so
+// that archived artifacts can be matched up. Empty disables the tagging.
+var runID string
+
+// parseRunID resolves the --run-id value, generating a random UUID for "auto".
+func parseRunID(value string) (string, error) {
+	if value != "auto" {
+		return value, nil
+	}
+
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", err
+	}
+	// Version 4, RFC 4122 variant.
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16]), nil
+}
+
+// useCRLF is set by --line-ending crlf and ends the lines of every CSV output
+// with \r\n instead of \n.
+var useCRLF bool
+
+// newCsvWriter returns a CSV writer on w, first writing a "# run-id: <id>"
+// comment line when a run ID is set.
+func newCsvWriter(w io.Writer) (*csv.Writer, error) {
+	if runID != "" {
+		eol := "\n"
+		if useCRLF {
+			eol = "\r\n"
+		}
+		if _, err := fmt.Fprintf(w, "# run-id: %s%s", runID, eol); err != nil {
+			return nil, err
+		}
+	}
+	writer := csv.NewWriter(w)
+	writer.UseCRLF = useCRLF
+	return writer, nil
+}
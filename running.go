@@ -0,0 +1,40 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/Finatext/measure-sfn/measure"
+)
+
+// writeRunning writes the executions running right now to running.csv, the
+// longest running first.
+func writeRunning(ctx context.Context, svc measure.Client, opts measure.Options) error {
+	running, err := measure.Running(ctx, svc, opts)
+	if err != nil {
+		return err
+	}
+
+	w, err := createOutput(outputPath("running.csv"))
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+	writer, err := newCsvWriter(w)
+	if err != nil {
+		return err
+	}
+
+	if err := writer.Write([]string{"Name", "ExecutionName", "ExecutionArn", "StartTime", durationHeader("Elapsed")}); err != nil {
+		return err
+	}
+
+	for _, r := range running {
+		if err := writer.Write([]string{r.Name, r.ExecutionName, r.ExecutionArn, r.StartTime.Format(time.RFC3339), formatDuration(r.Elapsed)}); err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/Finatext/measure-sfn/measure"
+)
+
+// createScheduleCsvFile writes the spread of the intervals between
+// consecutive starts of each state machine to schedule.csv. Intervals longer
+// than expected are counted as gaps, i.e. likely missed schedules. State
+// machines with a single execution have no interval and empty columns.
+func createScheduleCsvFile(aggregated measure.AggregatedRecordMap, expected time.Duration) error {
+	w, err := createOutput(outputPath("schedule.csv"))
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+	writer, err := newCsvWriter(w)
+	if err != nil {
+		return err
+	}
+
+	if err := writer.Write([]string{
+		"Name", "Executions",
+		durationHeader("MinInterval"), durationHeader("MedianInterval"), durationHeader("MaxInterval"),
+		"Gaps",
+	}); err != nil {
+		return err
+	}
+
+	for _, name := range aggregated.Names() {
+		records := aggregated[name]
+		row := []string{name, strconv.Itoa(records.Len()), "", "", "", ""}
+
+		if intervals := records.StartIntervals(); len(intervals) > 0 {
+			slices.Sort(intervals)
+			gaps := 0
+			for _, interval := range intervals {
+				if interval > expected {
+					gaps++
+				}
+			}
+			row[2] = formatDuration(intervals[0])
+			row[3] = formatDuration(median(intervals))
+			row[4] = formatDuration(intervals[len(intervals)-1])
+			row[5] = strconv.Itoa(gaps)
+		}
+		if err := writer.Write(row); err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+// median returns the median of sorted, non-empty durations.
+func median(sorted []time.Duration) time.Duration {
+	mid := len(sorted) / 2
+	if len(sorted)%2 == 1 {
+		return sorted[mid]
+	}
+	return (sorted[mid-1] + sorted[mid]) / 2
+}
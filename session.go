@@ -0,0 +1,409 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/credentials/stscreds"
+	"github.com/aws/aws-sdk-go/aws/defaults"
+	"github.com/aws/aws-sdk-go/aws/endpoints"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/sfn"
+)
+
+// defaultAssumeRoleDuration is the lifetime of assumed-role sessions unless
+// --assume-role-duration says otherwise.
+const defaultAssumeRoleDuration = 3600 * time.Second
+
+// assumeRoleExpiryWindow refreshes assumed-role credentials this long before
+// they expire, so that no page request of a long scan goes out with
+// credentials that expire in flight.
+const assumeRoleExpiryWindow = time.Minute
+
+type sessionConfig struct {
+	Profile string
+	// Region overrides the region of the profile when set.
+	Region string
+	// Partition pins endpoint resolution to the given partition. When empty
+	// the partition is inferred from the region.
+	Partition string
+	// RoleArn is assumed on top of the profile credentials when set.
+	RoleArn   string
+	MFASerial string
+	// MFAToken answers the first MFA prompt instead of stdin when set.
+	MFAToken string
+	// NonInteractive makes MFA prompts fail instead of blocking on stdin.
+	NonInteractive bool
+	// NoSharedConfig builds the session without reading ~/.aws/config, for
+	// environments with only environment credentials.
+	NoSharedConfig bool
+	// ConfigFile and CredentialsFile replace the shared config and
+	// credentials file locations when set. The other one keeps its default.
+	ConfigFile      string
+	CredentialsFile string
+	// RetryMode, MaxAttempts and RetryOn configure the SDK retryer, see
+	// newRetryer.
+	RetryMode   string
+	MaxAttempts int
+	RetryOn     []string
+	// STSRegion pins credential resolution to the regional STS endpoint of
+	// that region when set, instead of the SDK default.
+	STSRegion string
+	// APITimeout bounds each attempt of an API call, including reading the
+	// response, when positive. A timed out attempt is retried like any other
+	// transient error, with a fresh timeout; see attemptTimeout.
+	APITimeout time.Duration
+	// AssumeRoleDuration is the lifetime of assumed-role sessions, both of
+	// RoleArn and of role_arn profiles. Zero means the default of an hour.
+	AssumeRoleDuration time.Duration
+	// InsecureSkipVerify disables TLS certificate verification, for proxies
+	// re-signing traffic with a certificate the system does not trust.
+	InsecureSkipVerify bool
+}
+
+// httpClient returns the HTTP client of every API call, STS included. It
+// goes through the proxy of HTTP_PROXY, HTTPS_PROXY and NO_PROXY, as
+// http.DefaultTransport does.
+func (c sessionConfig) httpClient() *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = http.ProxyFromEnvironment
+	if c.InsecureSkipVerify {
+		transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+	}
+	return &http.Client{Transport: transport}
+}
+
+// handlers returns the handlers of the session and of every client built
+// from it, STS included: the SDK defaults, with each attempt bounded by
+// APITimeout when it is set.
+func (c sessionConfig) handlers() request.Handlers {
+	handlers := defaults.Handlers()
+	if c.APITimeout > 0 {
+		attemptTimeout(&handlers, c.APITimeout)
+	}
+	return handlers
+}
+
+// attemptTimeout bounds every attempt of the requests made with handlers to
+// d, from sending the request to reading the response, by a deadline on the
+// context of the attempt's HTTP request. Each deadline derives from the
+// context of the request rather than of the previous attempt, so a retry
+// gets a fresh d while cancelling the request still stops every attempt.
+func attemptTimeout(handlers *request.Handlers, d time.Duration) {
+	handlers.Build.PushBack(func(r *request.Request) {
+		// Build runs once per request, Send and CompleteAttempt once per
+		// attempt.
+		var cancel context.CancelFunc
+		r.Handlers.Send.PushFront(func(r *request.Request) {
+			var ctx context.Context
+			ctx, cancel = context.WithTimeout(r.Context(), d)
+			r.HTTPRequest = r.HTTPRequest.WithContext(ctx)
+		})
+		r.Handlers.CompleteAttempt.PushBack(func(r *request.Request) {
+			if cancel != nil {
+				cancel()
+			}
+			// A retry copies the HTTP request, and signing it reads the
+			// credentials with its context, which must not be the expired
+			// one.
+			r.HTTPRequest = r.HTTPRequest.WithContext(r.Context())
+		})
+	})
+}
+
+func (c sessionConfig) assumeRoleDuration() time.Duration {
+	if c.AssumeRoleDuration > 0 {
+		return c.AssumeRoleDuration
+	}
+	return defaultAssumeRoleDuration
+}
+
+var (
+	errMFARequired  = errors.New("an MFA token is required but --non-interactive is set")
+	errMFATokenUsed = errors.New("another MFA token is required but --mfa-token was already used; tokens are single use")
+)
+
+// mfaTokenUsed is shared by every session so that --mfa-token is only ever
+// sent once: STS rejects a reused token, which would fail more obscurely.
+var mfaTokenUsed atomic.Bool
+
+// tokenProvider supplies the MFA token when assuming a role with an MFA
+// serial. The SDK and stscreds only call it for role_arn profiles with
+// mfa_serial, or --role-arn with --mfa-serial; credential_process and SSO
+// profiles resolve through the shared config without prompting.
+func (c sessionConfig) tokenProvider() func() (string, error) {
+	if c.MFAToken != "" {
+		return func() (string, error) {
+			if mfaTokenUsed.Swap(true) {
+				return "", errMFATokenUsed
+			}
+			return c.MFAToken, nil
+		}
+	}
+	if c.NonInteractive {
+		return func() (string, error) { return "", errMFARequired }
+	}
+	return stscreds.StdinTokenProvider
+}
+
+// createSfnSession builds an SFN client from the given profile. When RoleArn
+// is set, the profile only supplies the base credentials and RoleArn is
+// assumed on top of them. Credentials are resolved eagerly so that MFA
+// prompts and slow credential providers are bounded by ctx.
+func createSfnSession(ctx context.Context, c sessionConfig) (*sfn.SFN, error) {
+	sess, creds, err := newSession(ctx, c)
+	if err != nil {
+		return nil, err
+	}
+	return sfn.New(sess, aws.NewConfig().WithCredentials(creds)), nil
+}
+
+// createSfnClients builds an SFN client per region, sharing one session and
+// set of credentials so that an MFA token is only asked for once. c.Region is
+// ignored, unless regions is nil: then the regions enabled for the account
+// are discovered from c.Region.
+func createSfnClients(ctx context.Context, c sessionConfig, regions []string) (map[string]*sfn.SFN, error) {
+	if regions != nil {
+		c.Region = regions[0]
+	}
+	sess, creds, err := newSession(ctx, c)
+	if err != nil {
+		return nil, err
+	}
+
+	if regions == nil {
+		if regions, err = enabledRegions(ctx, sess, creds); err != nil {
+			return nil, err
+		}
+	}
+
+	clients := make(map[string]*sfn.SFN, len(regions))
+	for _, region := range regions {
+		if c.Partition != "" {
+			if _, err := resolvePartition(c.Partition, region); err != nil {
+				return nil, err
+			}
+		}
+		clients[region] = sfn.New(sess, aws.NewConfig().WithCredentials(creds).WithRegion(region))
+	}
+	return clients, nil
+}
+
+func newSession(ctx context.Context, c sessionConfig) (*session.Session, *credentials.Credentials, error) {
+	cfg := aws.NewConfig()
+	if c.Region != "" {
+		cfg = cfg.WithRegion(c.Region)
+	}
+
+	retryer, err := newRetryer(c.RetryMode, c.MaxAttempts, c.RetryOn)
+	if err != nil {
+		return nil, nil, err
+	}
+	if retryer != nil {
+		cfg = request.WithRetryer(cfg, retryer)
+	}
+	cfg = cfg.WithHTTPClient(c.httpClient())
+
+	opt := session.Options{
+		Config:                  *cfg,
+		Handlers:                c.handlers(),
+		Profile:                 c.Profile,
+		AssumeRoleTokenProvider: c.tokenProvider(),
+		AssumeRoleDuration:      c.assumeRoleDuration(),
+		SharedConfigState:       session.SharedConfigEnable,
+	}
+	if c.NoSharedConfig {
+		opt.SharedConfigState = session.SharedConfigDisable
+	}
+	if c.ConfigFile != "" || c.CredentialsFile != "" {
+		config, credentials := sharedConfigFiles(c.ConfigFile, c.CredentialsFile)
+		// Later files take precedence, as in the SDK default order.
+		opt.SharedConfigFiles = []string{config, credentials}
+		if c.NoSharedConfig {
+			opt.SharedConfigFiles = []string{credentials}
+		}
+	}
+	if c.Partition != "" {
+		region := c.Region
+		if region == "" {
+			// The region may come from the profile, which only a session
+			// resolves. Creating one makes no calls.
+			probe, err := session.NewSessionWithOptions(opt)
+			if err != nil {
+				return nil, nil, err
+			}
+			region = aws.StringValue(probe.Config.Region)
+		}
+		p, err := resolvePartition(c.Partition, region)
+		if err != nil {
+			return nil, nil, err
+		}
+		// Resolve every endpoint, including STS for assume-role, within the
+		// partition so regions unknown to the SDK still get the right domain.
+		// The SDK builds the STS client of a role_arn profile while creating
+		// the session, so the resolver has to be set up front.
+		cfg = cfg.WithEndpointResolver(p)
+		opt.Config = *cfg
+	}
+	sess, err := session.NewSessionWithOptions(opt)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// stsSess resolves the credentials, so that both the assume-role of the
+	// profile and RoleArn go through the regional STS endpoint of STSRegion
+	// when it is set.
+	stsSess := sess
+	if c.STSRegion != "" {
+		stsCfg := cfg.Copy().WithRegion(c.STSRegion).WithSTSRegionalEndpoint(endpoints.RegionalSTSEndpoint)
+		if c.Partition != "" {
+			p, err := resolvePartition(c.Partition, c.STSRegion)
+			if err != nil {
+				return nil, nil, err
+			}
+			stsCfg = stsCfg.WithEndpointResolver(p)
+		}
+		stsOpt := opt
+		stsOpt.Config = *stsCfg
+		if stsSess, err = session.NewSessionWithOptions(stsOpt); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	creds := stsSess.Config.Credentials
+	if c.RoleArn != "" {
+		creds = stscreds.NewCredentials(stsSess, c.RoleArn, func(p *stscreds.AssumeRoleProvider) {
+			p.Duration = c.assumeRoleDuration()
+			p.ExpiryWindow = assumeRoleExpiryWindow
+			if c.MFASerial != "" {
+				p.SerialNumber = aws.String(c.MFASerial)
+				p.TokenProvider = c.tokenProvider()
+			}
+		})
+	}
+
+	if _, err := creds.GetWithContext(ctx); err != nil {
+		return nil, nil, fmt.Errorf("resolving credentials: %w", err)
+	}
+	warnExpiry(creds)
+	return sess, creds, nil
+}
+
+// credsExpiry is the earliest expiry of the credentials of the run, zero
+// while none expire. It bounds the scan estimate of warnScanTime.
+var (
+	credsExpiryMu sync.Mutex
+	credsExpiry   time.Time
+)
+
+// warnExpiry warns when the credentials expire before the --timeout deadline
+// of the run, or within the first --watch interval, and records their expiry
+// for warnScanTime. Credentials without an expiry, such as static keys, are
+// not checked.
+func warnExpiry(creds *credentials.Credentials) {
+	expiresAt, err := creds.ExpiresAt()
+	if err != nil {
+		return
+	}
+	credsExpiryMu.Lock()
+	if credsExpiry.IsZero() || expiresAt.Before(credsExpiry) {
+		credsExpiry = expiresAt
+	}
+	credsExpiryMu.Unlock()
+
+	lifetime := expiresAt.Sub(now())
+	switch {
+	case *timeout > 0 && *timeout > lifetime:
+		warnf("the credentials expire in %s, before the --timeout of %s; increase --assume-role-duration", lifetime.Round(time.Second), *timeout)
+	case *watch > 0 && *watch > lifetime:
+		warnf("the credentials expire in %s, before the next --watch cycle; increase --assume-role-duration", lifetime.Round(time.Second))
+	}
+}
+
+// machineScanEstimate is the time a state machine is assumed to take to
+// scan, about two ListExecutions pages, for the estimate of warnScanTime.
+const machineScanEstimate = 2 * time.Second
+
+// warnScanTime is the measure.Options.Listed hook. It warns before the scan
+// when scanning the listed state machines, --concurrency at a time, is
+// estimated to take longer than the credentials have left.
+func warnScanTime(machines int) {
+	credsExpiryMu.Lock()
+	expiresAt := credsExpiry
+	credsExpiryMu.Unlock()
+	if expiresAt.IsZero() {
+		return
+	}
+	lifetime := expiresAt.Sub(now())
+	if estimate := scanEstimate(machines, *concurrency); estimate > lifetime {
+		warnf("scanning %d state machines is estimated to take %s, but the credentials expire in %s; increase --assume-role-duration or --concurrency", machines, estimate, lifetime.Round(time.Second))
+	}
+}
+
+// scanEstimate estimates how long scanning machines state machines takes
+// with concurrency of them at a time.
+func scanEstimate(machines, concurrency int) time.Duration {
+	concurrency = max(concurrency, 1)
+	return time.Duration((machines+concurrency-1)/concurrency) * machineScanEstimate
+}
+
+// explainExpired annotates errors caused by expired credentials, which the SDK
+// reports as a bare ExpiredTokenException, with the flag that lengthens the
+// session. The AWS error stays in the chain for --json-errors.
+func explainExpired(err error) error {
+	var aerr awserr.Error
+	if errors.As(err, &aerr) && request.IsErrorExpiredCreds(aerr) {
+		return fmt.Errorf("session expired, increase --assume-role-duration: %w", err)
+	}
+	return err
+}
+
+// enabledRegions lists the regions enabled for the account with EC2
+// DescribeRegions, which leaves out the opt-in regions not opted in to.
+func enabledRegions(ctx context.Context, sess *session.Session, creds *credentials.Credentials) ([]string, error) {
+	if aws.StringValue(sess.Config.Region) == "" {
+		return nil, errors.New("discovering the regions needs a region to call EC2 in: set --region, AWS_REGION or the profile region")
+	}
+
+	out, err := ec2.New(sess, aws.NewConfig().WithCredentials(creds)).DescribeRegionsWithContext(ctx, &ec2.DescribeRegionsInput{})
+	if err != nil {
+		return nil, fmt.Errorf("discovering the regions: %w", err)
+	}
+	regions := make([]string, len(out.Regions))
+	for i, r := range out.Regions {
+		regions[i] = aws.StringValue(r.RegionName)
+	}
+	if len(regions) == 0 {
+		return nil, errors.New("discovering the regions: no region is enabled")
+	}
+	slices.Sort(regions)
+	return regions, nil
+}
+
+// resolvePartition looks up the partition with the given ID and makes sure the
+// region, when known to the SDK, belongs to it.
+func resolvePartition(id, region string) (endpoints.Partition, error) {
+	partitions := endpoints.DefaultPartitions()
+	for _, p := range partitions {
+		if p.ID() != id {
+			continue
+		}
+		if actual, ok := endpoints.PartitionForRegion(partitions, region); ok && actual.ID() != id {
+			return endpoints.Partition{}, fmt.Errorf("region %s belongs to partition %s, not %s", region, actual.ID(), id)
+		}
+		return p, nil
+	}
+	return endpoints.Partition{}, fmt.Errorf("unknown partition: %s", id)
+}
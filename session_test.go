@@ -0,0 +1,163 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/sfn"
+)
+
+// TestNewSessionPartitionRoleArnProfile checks that the STS client the SDK
+// builds for a role_arn profile while creating the session resolves within
+// --partition, by recording the host each request is proxied to. weird-1
+// matches no partition, so the default resolver would pick the aws one.
+// http.ProxyFromEnvironment reads the environment once per process, which
+// leaves other tests unaffected as loopback requests are never proxied.
+func TestNewSessionPartitionRoleArnProfile(t *testing.T) {
+	var (
+		mu    sync.Mutex
+		hosts []string
+	)
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		hosts = append(hosts, r.Host)
+		mu.Unlock()
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer proxy.Close()
+	t.Setenv("HTTPS_PROXY", proxy.URL)
+
+	dir := t.TempDir()
+	config := filepath.Join(dir, "config")
+	credentials := filepath.Join(dir, "credentials")
+	writeFile(t, config, "[profile role]\nrole_arn = arn:aws-cn:iam::123456789012:role/measure\nsource_profile = base\nregion = weird-1\n")
+	writeFile(t, credentials, "[base]\naws_access_key_id = AKID\naws_secret_access_key = SECRET\n")
+
+	_, _, err := newSession(context.Background(), sessionConfig{
+		Profile:         "role",
+		Partition:       "aws-cn",
+		ConfigFile:      config,
+		CredentialsFile: credentials,
+		MaxAttempts:     1,
+		NonInteractive:  true,
+	})
+	if err == nil {
+		t.Fatal("newSession() succeeded against a proxy refusing every request")
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(hosts) == 0 {
+		t.Fatalf("no request went through the proxy: %v", err)
+	}
+	for _, host := range hosts {
+		if want := "sts.weird-1.amazonaws.com.cn:443"; host != want {
+			t.Errorf("assume-role went to %s, want %s", host, want)
+		}
+	}
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestScanEstimate(t *testing.T) {
+	tests := []struct {
+		machines, concurrency int
+		want                  time.Duration
+	}{
+		{0, 1, 0},
+		{3, 1, 3 * machineScanEstimate},
+		{3, 2, 2 * machineScanEstimate},
+		{3, 0, 3 * machineScanEstimate},
+		{2000, 4, 500 * machineScanEstimate},
+	}
+	for _, tt := range tests {
+		if got := scanEstimate(tt.machines, tt.concurrency); got != tt.want {
+			t.Errorf("scanEstimate(%d, %d) = %s, want %s", tt.machines, tt.concurrency, got, tt.want)
+		}
+	}
+}
+
+func TestAPITimeoutPerAttempt(t *testing.T) {
+	var attempts atomic.Int32
+	stall := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts.Add(1)
+		select {
+		case <-r.Context().Done():
+		case <-stall:
+		}
+	}))
+	defer server.Close()
+	defer close(stall)
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKID")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "SECRET")
+
+	sess, creds, err := newSession(context.Background(), sessionConfig{
+		Region:         "us-east-1",
+		NoSharedConfig: true,
+		MaxAttempts:    3,
+		APITimeout:     50 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	svc := sfn.New(sess, aws.NewConfig().WithCredentials(creds).WithEndpoint(server.URL))
+
+	start := time.Now()
+	_, err = svc.ListStateMachinesWithContext(context.Background(), &sfn.ListStateMachinesInput{})
+	if err == nil {
+		t.Fatal("ListStateMachines() succeeded against a stalled server")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("3 attempts took %s, want about 3 × 50ms plus the retry delays", elapsed)
+	}
+	if got := attempts.Load(); got != 3 {
+		t.Errorf("the server saw %d attempts, want 3: a timed out attempt is retried with a fresh timeout", got)
+	}
+	var aerr awserr.Error
+	if !errors.As(err, &aerr) || aerr.Code() == request.CanceledErrorCode {
+		t.Errorf("got %v, want a timed out send error rather than a cancellation", err)
+	}
+}
+
+func TestAPITimeoutFastResponse(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/x-amz-json-1.0")
+		w.Write([]byte(`{"stateMachines":[{"name":"a"}]}`))
+	}))
+	defer server.Close()
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKID")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "SECRET")
+
+	sess, creds, err := newSession(context.Background(), sessionConfig{
+		Region:         "us-east-1",
+		NoSharedConfig: true,
+		APITimeout:     time.Second,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	svc := sfn.New(sess, aws.NewConfig().WithCredentials(creds).WithEndpoint(server.URL))
+	out, err := svc.ListStateMachinesWithContext(context.Background(), &sfn.ListStateMachinesInput{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out.StateMachines) != 1 {
+		t.Errorf("got %d state machines, want the response read in full", len(out.StateMachines))
+	}
+}
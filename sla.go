@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Finatext/measure-sfn/measure"
+)
+
+// slaTarget requires the given percentile of each state machine's durations
+// to stay under max.
+type slaTarget struct {
+	label      string
+	percentile float64
+	max        time.Duration
+}
+
+// sla is set by --sla. Nil disables the SLA evaluation.
+var sla *slaTarget
+
+// parseSLA parses a target such as "p99:30s". An empty value yields nil.
+func parseSLA(value string) (*slaTarget, error) {
+	if value == "" {
+		return nil, nil
+	}
+
+	label, limit, ok := strings.Cut(value, ":")
+	p, err := strconv.ParseFloat(strings.TrimPrefix(label, "p"), 64)
+	if !ok || !strings.HasPrefix(label, "p") || err != nil || p <= 0 || p > 100 {
+		return nil, fmt.Errorf("invalid --sla %q: want a percentile and a duration such as p99:30s", value)
+	}
+	max, err := time.ParseDuration(limit)
+	if err != nil || max <= 0 {
+		return nil, fmt.Errorf("invalid --sla %q: want a positive duration after the colon", value)
+	}
+	return &slaTarget{label: label, percentile: p, max: max}, nil
+}
+
+func (t slaTarget) String() string {
+	return fmt.Sprintf("%s < %s", t.label, t.max)
+}
+
+func (t slaTarget) met(records measure.SfnRecords) bool {
+	return records.Percentile(t.percentile) < t.max
+}
+
+// reportSLABreaches writes one line per state machine breaching sla to w and
+// returns their number.
+func reportSLABreaches(w io.Writer, aggregated measure.AggregatedRecordMap) int {
+	breaches := 0
+	for _, name := range aggregated.Names() {
+		records := aggregated[name]
+		if sla.met(records) {
+			continue
+		}
+		breaches++
+		fmt.Fprintf(w, "%s breaches the SLA %s: %s is %s\n", name, sla, sla.label, records.Percentile(sla.percentile))
+	}
+	return breaches
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"time"
+
+	"github.com/Finatext/measure-sfn/measure"
+)
+
+func createSlowestCsvFile(records measure.SfnRecords) error {
+	w, err := createOutput(outputPath("slowest.csv"))
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+	writer, err := newCsvWriter(w)
+	if err != nil {
+		return err
+	}
+
+	if err := writer.Write([]string{"Name", "ExecutionName", "StartDate", "StartTimestamp", durationHeader("Duration"), "Status"}); err != nil {
+		return err
+	}
+
+	for _, r := range records {
+		if err := writer.Write([]string{r.Name, r.ExecutionName, formatStartDate(r), r.StartTime.Format(time.RFC3339Nano), formatDuration(r.Duration), r.Status}); err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
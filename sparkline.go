@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/Finatext/measure-sfn/measure"
+)
+
+// sparkBlocks are the sparkline levels from the fewest to the most executions.
+var sparkBlocks = []rune("▁▂▃▄▅▆▇█")
+
+// hourCounts counts the records by the hour of day they started at in the
+// configured time zone.
+func hourCounts(records measure.SfnRecords) [24]int {
+	var counts [24]int
+	for _, record := range records {
+		counts[record.StartTime.Hour()]++
+	}
+	return counts
+}
+
+// sparkline renders one character per hour scaled to the busiest hour. Hours
+// without executions are blank so that they stand out from quiet ones.
+func sparkline(counts [24]int) string {
+	busiest := 0
+	for _, n := range counts {
+		busiest = max(busiest, n)
+	}
+
+	var b strings.Builder
+	for _, n := range counts {
+		if n == 0 {
+			b.WriteRune(' ')
+			continue
+		}
+		b.WriteRune(sparkBlocks[(n*len(sparkBlocks)-1)/busiest])
+	}
+	return b.String()
+}
+
+// printSparklines writes the executions per hour of day of every state machine
+// to w as a 24-character sparkline from 00 to 23, sorted by name.
+func printSparklines(w io.Writer, aggregated measure.AggregatedRecordMap) error {
+	names := aggregated.Names()
+	width := 0
+	for _, name := range names {
+		width = max(width, utf8.RuneCountInString(displayName(name)))
+	}
+
+	for _, name := range names {
+		display := displayName(name)
+		padding := strings.Repeat(" ", width-utf8.RuneCountInString(display))
+		if _, err := fmt.Fprintf(w, "%s%s |%s| %s\n", display, padding, sparkline(hourCounts(aggregated[name])), humanNumber(fmt.Sprint(aggregated[name].Len()))); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// recordsToStdout reports whether a records file is written to stdout, where
+// terminal output would be interleaved with the records.
+func recordsToStdout() bool {
+	stdout, err := os.Stdout.Stat()
+	if err != nil {
+		return false
+	}
+	for _, format := range outputFormats {
+		if info, err := os.Stat(recordsPath(format)); err == nil && os.SameFile(info, stdout) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/Finatext/measure-sfn/measure"
+)
+
+// createStatusCountsCsvFile writes the number of executions per state machine
+// and status to status-counts.csv in long format, one row per pair that
+// occurred, sorted by name and then status.
+func createStatusCountsCsvFile(aggregated measure.AggregatedRecordMap) error {
+	w, err := createOutput(outputPath("status-counts.csv"))
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+	writer, err := newCsvWriter(w)
+	if err != nil {
+		return err
+	}
+
+	if err := writer.Write([]string{"Name", "Status", "Count"}); err != nil {
+		return err
+	}
+
+	for _, name := range aggregated.Names() {
+		byStatus := aggregated[name].GroupByStatus()
+		for _, status := range sortedStatuses(byStatus) {
+			if err := writer.Write([]string{name, status, strconv.Itoa(byStatus[status].Len())}); err != nil {
+				return err
+			}
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+// createStatusesCsvFile writes the distinct statuses each state machine
+// produced to statuses.csv, sorted and separated by semicolons, e.g. to check
+// that a timeout path was ever taken.
+func createStatusesCsvFile(aggregated measure.AggregatedRecordMap) error {
+	w, err := createOutput(outputPath("statuses.csv"))
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+	writer, err := newCsvWriter(w)
+	if err != nil {
+		return err
+	}
+
+	if err := writer.Write([]string{"Name", "Statuses"}); err != nil {
+		return err
+	}
+
+	for _, name := range aggregated.Names() {
+		statuses := sortedStatuses(aggregated[name].GroupByStatus())
+		if err := writer.Write([]string{name, strings.Join(statuses, ";")}); err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+func sortedStatuses(byStatus map[string]measure.SfnRecords) []string {
+	statuses := make([]string, 0, len(byStatus))
+	for status := range byStatus {
+		statuses = append(statuses, status)
+	}
+	sort.Strings(statuses)
+	return statuses
+}
+
+// createStatusTrendCsvFile writes the number of executions per state machine,
+// ISO week and status to status-trend.csv in long format, sorted by name,
+// week and status, to chart whether failures are increasing.
+func createStatusTrendCsvFile(aggregated measure.AggregatedRecordMap) error {
+	w, err := createOutput(outputPath("status-trend.csv"))
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+	writer, err := newCsvWriter(w)
+	if err != nil {
+		return err
+	}
+
+	if err := writer.Write([]string{"Name", "Week", "Status", "Count"}); err != nil {
+		return err
+	}
+
+	for _, name := range aggregated.Names() {
+		byWeek := aggregated[name].GroupByWeek()
+		weeks := make([]string, 0, len(byWeek))
+		for week := range byWeek {
+			weeks = append(weeks, week)
+		}
+		sort.Strings(weeks)
+
+		for _, week := range weeks {
+			byStatus := byWeek[week].GroupByStatus()
+			for _, status := range sortedStatuses(byStatus) {
+				if err := writer.Write([]string{name, week, status, strconv.Itoa(byStatus[status].Len())}); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+
+	"github.com/Finatext/measure-sfn/measure"
+)
+
+// streamIncompatible lists the flags that need every record in memory and
+// therefore cannot be combined with --stream.
+var streamIncompatible = map[string]bool{
+	"format":            true,
+	"with-schema":       true,
+	"template":          true,
+	"per-machine-dir":   true,
+	"split-by-status":   true,
+	"baseline-json":     true,
+	"tail-threshold":    true,
+	"aggregate-json":    true,
+	"utilization":       true,
+	"peak-concurrency":  true,
+	"with-arn":          true,
+	"tiers":             true,
+	"relative":          true,
+	"health-score":      true,
+	"health-weights":    true,
+	"sort-aggregate":    true,
+	"tier-fast":         true,
+	"tier-slow":         true,
+	"name-map":          true,
+	"group-by-expr":     true,
+	"top":               true,
+	"group-by":          true,
+	"status-counts":     true,
+	"statuses":          true,
+	"status-trend":      true,
+	"weekday-weekend":   true,
+	"histogram":         true,
+	"expected-interval": true,
+	"group-by-tag":      true,
+	"by-alias":          true,
+	"html-report":       true,
+	"github-summary":    true,
+	"otlp-endpoint":     true,
+	"chart":             true,
+	"sparkline":         true,
+	"state-breakdown":   true,
+	"timeline":          true,
+	"max-p95":           true,
+	"sla":               true,
+	"fail-on-sla":       true,
+	"no-aggregate":      true,
+	"assert-window":     true,
+	"percentile-band":   true,
+	"compact-aggregate": true,
+	"only-aggregate":    true,
+	"with-map-items":    true,
+	"link-children":     true,
+	"failure-summary":   true,
+	"slowest":           true,
+}
+
+// runStream writes each state machine's records to sfn.ndjson as soon as they
+// have been collected and then drops them, keeping only a measure.Accumulator per
+// state machine for aggregate.csv. Memory use is bounded by the busiest state
+// machine rather than the whole account, at the cost of exact percentiles: the
+// P50 to P999 columns are within 1% of the true durations, and the in-memory
+// mode remains the one to use for exact values. The records are tagged with
+// the account and environment of t as in collectTargets.
+func runStream(ctx context.Context, t target, opts measure.Options) (measure.Result, error) {
+	w, err := createOutput(outputPath("sfn.ndjson"))
+	if err != nil {
+		return measure.Result{}, err
+	}
+	defer w.Close()
+	bw := bufio.NewWriter(w)
+
+	columns := recordColumns()
+	summaries := make(map[string]*measure.Accumulator)
+	written := 0
+
+	result, err := measure.Stream(ctx, t.client, opts, func(_ string, records measure.SfnRecords) error {
+		t.tag(records)
+		for _, record := range records {
+			line, err := json.Marshal(jsonRecord{record: record, columns: columns})
+			if err != nil {
+				return err
+			}
+			bw.Write(line)
+			if err := bw.WriteByte('\n'); err != nil {
+				return err
+			}
+			// The records of one state machine span several names with
+			// --name-style name-version, one per version.
+			if _, ok := summaries[record.Name]; !ok {
+				summaries[record.Name] = &measure.Accumulator{}
+			}
+		}
+		// Every record of the state machine is at hand, so its warmup can be
+		// told apart by start time.
+		for _, record := range records.SkipFirst(*skipFirst) {
+			if !*minNonZero || record.Duration != 0 {
+				summaries[record.Name].Add(record)
+			}
+		}
+		written += len(records)
+		return nil
+	})
+	if ctx.Err() != nil {
+		fmt.Fprintf(os.Stderr, "interrupted (%v): writing partial results (%d records)\n", ctx.Err(), written)
+	} else if err != nil {
+		return result, err
+	}
+
+	if err := bw.Flush(); err != nil {
+		return result, err
+	}
+	if err := w.Close(); err != nil {
+		return result, err
+	}
+
+	if *minExecutions > 0 {
+		for name, summary := range summaries {
+			if summary.Count < *minExecutions {
+				delete(summaries, name)
+			}
+		}
+	}
+	return result, createSummaryCsvFile(summaries)
+}
+
+type summaryColumn struct {
+	Header string
+	Value  func(*measure.Accumulator) string
+}
+
+// summaryColumns mirrors aggregateColumns for measure.Accumulator.
+func summaryColumns() []summaryColumn {
+	return []summaryColumn{
+		{durationHeader("Max"), func(s *measure.Accumulator) string { return formatDuration(s.Max) }},
+		{durationHeader("Min"), func(s *measure.Accumulator) string { return formatDuration(s.Min) }},
+		{durationHeader("Avg"), func(s *measure.Accumulator) string { return formatDuration(s.AvgDuration()) }},
+		{"Len", func(s *measure.Accumulator) string { return strconv.Itoa(s.Count) }},
+		{"CV", func(s *measure.Accumulator) string { return fmt.Sprintf("%.2f", s.CoefficientOfVariation()) }},
+		{"ActiveDays", func(s *measure.Accumulator) string { return strconv.Itoa(s.ActiveDays()) }},
+		{durationHeader("P50"), func(s *measure.Accumulator) string { return formatDuration(s.Percentile(50)) }},
+		{durationHeader("P90"), func(s *measure.Accumulator) string { return formatDuration(s.Percentile(90)) }},
+		{durationHeader("P99"), func(s *measure.Accumulator) string { return formatDuration(s.Percentile(99)) }},
+		{durationHeader("P999"), func(s *measure.Accumulator) string { return formatDuration(s.Percentile(99.9)) }},
+	}
+}
+
+func createSummaryCsvFile(summaries map[string]*measure.Accumulator) error {
+	w, err := createOutput(outputPath("aggregate.csv"))
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+	writer, err := newCsvWriter(w)
+	if err != nil {
+		return err
+	}
+
+	columns := summaryColumns()
+
+	header := []string{"Name"}
+	for _, column := range columns {
+		header = append(header, column.Header)
+	}
+	rows := [][]string{header}
+
+	names := make([]string, 0, len(summaries))
+	for name := range summaries {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		row := []string{name}
+		for _, column := range columns {
+			row = append(row, column.Value(summaries[name]))
+		}
+		rows = append(rows, row)
+	}
+
+	if *transpose {
+		rows = transposeRows(rows)
+	}
+	if *noHeader {
+		rows = rows[1:]
+	}
+	return writer.WriteAll(rows)
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"time"
+
+	"github.com/Finatext/measure-sfn/measure"
+)
+
+func createStuckCsvFile(stuck []measure.StuckExecution) error {
+	w, err := createOutput(outputPath("stuck.csv"))
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+	writer, err := newCsvWriter(w)
+	if err != nil {
+		return err
+	}
+
+	if err := writer.Write([]string{"Name", "ExecutionArn", "StartTime", durationHeader("Elapsed")}); err != nil {
+		return err
+	}
+
+	for _, s := range stuck {
+		if err := writer.Write([]string{s.Name, s.ExecutionArn, s.StartTime.Format(time.RFC3339), formatDuration(s.Elapsed)}); err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
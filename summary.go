@@ -0,0 +1,39 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/Finatext/measure-sfn/measure"
+)
+
+// printStatusSummary writes one line with the count and average duration of
+// the records per status, e.g. "SUCCEEDED: 2900 (avg 10.10s), FAILED: 120
+// (avg 4.30s)". Statuses are ordered by count, most frequent first.
+func printStatusSummary(w io.Writer, records measure.SfnRecords) {
+	if len(records) == 0 {
+		return
+	}
+
+	byStatus := records.GroupByStatus()
+	statuses := make([]string, 0, len(byStatus))
+	for status := range byStatus {
+		statuses = append(statuses, status)
+	}
+	sort.Slice(statuses, func(i, j int) bool {
+		if len(byStatus[statuses[i]]) != len(byStatus[statuses[j]]) {
+			return len(byStatus[statuses[i]]) > len(byStatus[statuses[j]])
+		}
+		return statuses[i] < statuses[j]
+	})
+
+	parts := make([]string, len(statuses))
+	for i, status := range statuses {
+		group := byStatus[status]
+		parts[i] = fmt.Sprintf("%s: %s (avg %s%s)", status, humanNumber(strconv.Itoa(len(group))), humanNumber(formatDuration(group.AvgDuration())), outputUnit.symbol)
+	}
+	fmt.Fprintln(w, strings.Join(parts, ", "))
+}
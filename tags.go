@@ -0,0 +1,122 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/Finatext/measure-sfn/measure"
+)
+
+// tagCache stores ListTagsForResource results under dir/tags, one JSON file
+// per state machine ARN, gzip-compressed with compress. Entries older than ttl
+// are refetched. An empty dir disables caching.
+type tagCache struct {
+	dir      string
+	ttl      time.Duration
+	compress bool
+}
+
+type tagCacheEntry struct {
+	FetchedAt time.Time         `json:"fetched_at"`
+	Tags      map[string]string `json:"tags"`
+}
+
+func (c tagCache) path(stateMachineArn string) string {
+	return filepath.Join(c.dir, "tags", sanitizeFileName(stateMachineArn)+".json")
+}
+
+// load returns the cached tags of the state machine if they are fresh. A
+// missing or unreadable entry is a miss.
+func (c tagCache) load(stateMachineArn string) (map[string]string, bool) {
+	if c.dir == "" {
+		return nil, false
+	}
+
+	b, err := os.ReadFile(c.path(stateMachineArn))
+	if err != nil {
+		return nil, false
+	}
+	// Compressed entries are told apart by the gzip magic number rather than
+	// by the name, so that toggling --cache-compress keeps the cache valid.
+	if bytes.HasPrefix(b, []byte{0x1f, 0x8b}) {
+		r, err := gzip.NewReader(bytes.NewReader(b))
+		if err != nil {
+			return nil, false
+		}
+		if b, err = io.ReadAll(r); err != nil {
+			return nil, false
+		}
+	}
+	var entry tagCacheEntry
+	if err := json.Unmarshal(b, &entry); err != nil || now().Sub(entry.FetchedAt) > c.ttl {
+		return nil, false
+	}
+	return entry.Tags, true
+}
+
+func (c tagCache) store(stateMachineArn string, tags map[string]string) error {
+	if c.dir == "" {
+		return nil
+	}
+
+	path := c.path(stateMachineArn)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	b, err := json.Marshal(tagCacheEntry{FetchedAt: now(), Tags: tags})
+	if err != nil {
+		return err
+	}
+	if c.compress {
+		var buf bytes.Buffer
+		w := gzip.NewWriter(&buf)
+		if _, err := w.Write(b); err != nil {
+			return err
+		}
+		if err := w.Close(); err != nil {
+			return err
+		}
+		b = buf.Bytes()
+	}
+	return os.WriteFile(path, b, 0o644)
+}
+
+// fetchTags returns the tags of every state machine in aggregated keyed by
+// state machine ARN, calling ListTagsForResource only on cache misses.
+func fetchTags(ctx context.Context, client measure.Client, aggregated measure.AggregatedRecordMap, cache tagCache) (map[string]map[string]string, error) {
+	tags := make(map[string]map[string]string)
+	for _, name := range aggregated.Names() {
+		arn := aggregated[name][0].StateMachineArn
+		if t, ok := cache.load(arn); ok {
+			tags[arn] = t
+			continue
+		}
+
+		t, err := measure.StateMachineTags(ctx, client, arn)
+		if err != nil {
+			return nil, err
+		}
+		if err := cache.store(arn, t); err != nil {
+			warnf("caching tags of %s: %v", arn, err)
+		}
+		tags[arn] = t
+	}
+	return tags, nil
+}
+
+// groupByTag regroups the records of every state machine by the value of its
+// tag key. Untagged state machines share the empty value.
+func groupByTag(aggregated measure.AggregatedRecordMap, key string, tags map[string]map[string]string) measure.AggregatedRecordMap {
+	grouped := make(measure.AggregatedRecordMap)
+	for _, records := range aggregated {
+		value := tags[records[0].StateMachineArn][key]
+		grouped[value] = append(grouped[value], records...)
+	}
+	return grouped
+}
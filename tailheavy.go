@@ -0,0 +1,45 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/Finatext/measure-sfn/measure"
+)
+
+// createTailHeavyCsvFile writes the state machines whose TailRatio exceeds
+// threshold to tail-heavy.csv, i.e. those whose average understates how long
+// the slow executions take.
+func createTailHeavyCsvFile(aggregated measure.AggregatedRecordMap, threshold float64) error {
+	w, err := createOutput(outputPath("tail-heavy.csv"))
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+	writer, err := newCsvWriter(w)
+	if err != nil {
+		return err
+	}
+
+	if err := writer.Write([]string{"Name", durationHeader("Avg"), durationHeader("P95"), "TailRatio"}); err != nil {
+		return err
+	}
+
+	for _, name := range aggregated.Names() {
+		records := aggregated[name]
+		ratio := records.TailRatio()
+		if ratio <= threshold {
+			continue
+		}
+		if err := writer.Write([]string{
+			name,
+			formatDuration(records.AvgDuration()),
+			formatDuration(records.Percentile(95)),
+			fmt.Sprintf("%.2f", ratio),
+		}); err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/Finatext/measure-sfn/measure"
+	"github.com/aws/aws-sdk-go/service/sfn"
+)
+
+// multiTargetIncompatible lists the flags that work with a single client and
+// therefore cannot be combined with several --profile or --region values.
+var multiTargetIncompatible = map[string]bool{
+	"state-machine-arn": true,
+	"execution-arn":     true,
+	"count-only":        true,
+	"only-running":      true,
+	"failure-summary":   true,
+	"state-breakdown":   true,
+	"group-by-tag":      true,
+	"stream":            true,
+	"with-map-items":    true,
+	"link-children":     true,
+}
+
+// splitList splits a comma-separated flag value. A single value, including
+// the empty one deferring to the environment, yields one element.
+func splitList(value string) []string {
+	if !strings.Contains(value, ",") {
+		return []string{value}
+	}
+
+	var values []string
+	for _, v := range strings.Split(value, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+// target is one profile and region pair to collect from. With --org the
+// profile only lists the accounts, and account is the one measured. env is
+// the --env environment the pair was selected by.
+type target struct {
+	env         string
+	profile     string
+	region      string
+	account     string
+	accountName string
+	client      *sfn.SFN
+}
+
+func (t target) String() string {
+	var parts []string
+	if t.env != "" {
+		parts = append(parts, "env "+t.env)
+	}
+	if t.account != "" {
+		parts = append(parts, "account "+t.account)
+	} else if t.profile != "" {
+		parts = append(parts, "profile "+t.profile)
+	}
+	if t.region != "" {
+		parts = append(parts, "region "+t.region)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// createTargets builds a client per profile and region. The profiles are set
+// up one after the other so that their MFA prompts never compete for stdin.
+// Nil regions scans every region enabled for the account of each profile.
+// As in collectTargets, a profile that fails to load is skipped and reported
+// as a warning once every profile has been tried; only when every profile
+// fails, or the context is done, is the first error returned.
+func createTargets(ctx context.Context, c sessionConfig, profiles, regions []string) ([]target, error) {
+	var targets []target
+	var errs []error
+	for _, profile := range profiles {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		c.Profile = profile
+
+		if len(regions) == 1 {
+			c.Region = regions[0]
+			svc, err := createSfnSession(ctx, c)
+			if err != nil {
+				errs = append(errs, withProfile(profile, len(profiles), err))
+				continue
+			}
+			targets = append(targets, target{profile: profile, region: regions[0], client: svc})
+			continue
+		}
+
+		clients, err := createSfnClients(ctx, c, regions)
+		if err != nil {
+			errs = append(errs, withProfile(profile, len(profiles), err))
+			continue
+		}
+		profileRegions := regions
+		if profileRegions == nil {
+			for region := range clients {
+				profileRegions = append(profileRegions, region)
+			}
+			slices.Sort(profileRegions)
+		}
+		for _, region := range profileRegions {
+			targets = append(targets, target{profile: profile, region: region, client: clients[region]})
+		}
+	}
+
+	if len(targets) == 0 && len(errs) > 0 {
+		return nil, errs[0]
+	}
+	for _, err := range errs {
+		warnf("skipping %v", err)
+	}
+	return targets, nil
+}
+
+// targetRegions returns the distinct regions of targets in order.
+func targetRegions(targets []target) []string {
+	var regions []string
+	for _, t := range targets {
+		if !slices.Contains(regions, t.region) {
+			regions = append(regions, t.region)
+		}
+	}
+	return regions
+}
+
+func withProfile(profile string, profiles int, err error) error {
+	if profiles == 1 {
+		return err
+	}
+	return &targetError{target: target{profile: profile}, err: err}
+}
+
+// targetError is a failure of one target, identifying it for --json-errors.
+type targetError struct {
+	target target
+	err    error
+}
+
+func (e *targetError) Error() string {
+	return fmt.Sprintf("%s: %v", e.target, e.err)
+}
+
+func (e *targetError) Unwrap() error {
+	return e.err
+}
+
+// tag sets the account and environment of the target on the records.
+func (t target) tag(records measure.SfnRecords) {
+	for i := range records {
+		records[i].Account = t.account
+		records[i].AccountName = t.accountName
+		records[i].Env = t.env
+	}
+}
+
+// collectTargets collects up to concurrency targets at a time and merges the
+// results in the order of targets. A failing target is reported as a warning
+// and contributes whatever it collected; only when every target fails is the
+// first error returned.
+func collectTargets(ctx context.Context, targets []target, opts measure.Options, concurrency int) (measure.Result, error) {
+	results := make([]measure.Result, len(targets))
+	errs := make([]error, len(targets))
+
+	sem := make(chan struct{}, max(concurrency, 1))
+	var wg sync.WaitGroup
+	for i, t := range targets {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			results[i], errs[i] = measure.Collect(ctx, t.client, opts)
+			t.tag(results[i].Records)
+		}()
+	}
+	wg.Wait()
+
+	var merged measure.Result
+	failed := 0
+	for i, r := range results {
+		merged.Records = append(merged.Records, r.Records...)
+		merged.Stuck = append(merged.Stuck, r.Stuck...)
+		merged.Idle = append(merged.Idle, r.Idle...)
+		merged.Anomalies = append(merged.Anomalies, r.Anomalies...)
+		merged.Truncated = merged.Truncated || r.Truncated
+		if errs[i] != nil {
+			failed++
+			if ctx.Err() == nil {
+				warnf("%s: %v", targets[i], errs[i])
+			}
+		}
+	}
+	if failed == len(targets) {
+		return merged, &targetError{target: targets[0], err: errs[0]}
+	}
+	return merged, nil
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"text/template"
+	"time"
+
+	"github.com/Finatext/measure-sfn/measure"
+)
+
+// parseRecordTemplate compiles a per-record template. Besides the measure.SfnRecord
+// fields, templates can call "duration" to format a duration in the --unit
+// unit, e.g. {{.Name}},{{duration .Duration}}.
+func parseRecordTemplate(text string) (*template.Template, error) {
+	tmpl, err := template.New("record").Funcs(template.FuncMap{
+		"duration": func(d time.Duration) string { return formatDuration(d) },
+	}).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid --template: %w", err)
+	}
+	return tmpl, nil
+}
+
+// renderTemplate executes tmpl for every record, one line per record.
+func renderTemplate(w io.Writer, tmpl *template.Template, records measure.SfnRecords) error {
+	bw := bufio.NewWriter(w)
+	for _, record := range records {
+		if err := tmpl.Execute(bw, record); err != nil {
+			return err
+		}
+		if err := bw.WriteByte('\n'); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
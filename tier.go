@@ -0,0 +1,62 @@
+package main
+
+import (
+	"time"
+
+	"github.com/Finatext/measure-sfn/measure"
+)
+
+// tierThresholds splits durations into the fast, normal and slow tiers.
+type tierThresholds struct {
+	fast, slow time.Duration
+}
+
+// machineTiers holds the tier thresholds per state machine name, prepared by
+// prepareTiers.
+var machineTiers map[string]tierThresholds
+
+func tiersEnabled() bool {
+	return *tiers || *tierFast > 0 || *tierSlow > 0
+}
+
+// prepareTiers sets the thresholds of every state machine of records. A
+// threshold not given by --tier-fast or --tier-slow defaults to the 25th or
+// 75th percentile of the state machine's own durations.
+func prepareTiers(records measure.SfnRecords) {
+	machineTiers = make(map[string]tierThresholds)
+	for name, r := range records.Aggregate() {
+		t := tierThresholds{fast: *tierFast, slow: *tierSlow}
+		if t.fast <= 0 {
+			t.fast = r.Percentile(25)
+		}
+		if t.slow <= 0 {
+			t.slow = r.Percentile(75)
+		}
+		machineTiers[name] = t
+	}
+}
+
+// tierOf returns "fast" for executions under the fast threshold of their
+// state machine, "slow" for those over the slow threshold and "normal"
+// otherwise.
+func tierOf(record measure.SfnRecord) string {
+	t := machineTiers[record.Name]
+	switch {
+	case record.Duration < t.fast:
+		return "fast"
+	case record.Duration > t.slow:
+		return "slow"
+	}
+	return "normal"
+}
+
+// countTier returns the number of records in the tier.
+func countTier(records measure.SfnRecords, tier string) int {
+	n := 0
+	for _, record := range records {
+		if tierOf(record) == tier {
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/Finatext/measure-sfn/measure"
+)
+
+// startDateLayout is set by --time-format and formats the StartDate column.
+// Grouping by day always uses the calendar date regardless of it.
+var startDateLayout = time.DateOnly
+
+// parseTimeFormat validates a Go reference-time layout by formatting a sample
+// time with it: a layout without any reference element formats to itself.
+func parseTimeFormat(layout string) (string, error) {
+	sample := time.Date(2001, time.February, 3, 4, 5, 6, 0, time.UTC)
+	if layout == "" || sample.Format(layout) == layout {
+		return "", fmt.Errorf("invalid --time-format %q: want a Go reference-time layout such as 2006-01-02 15:04:05", layout)
+	}
+	return layout, nil
+}
+
+// formatStartDate formats the start of r with startDateLayout.
+func formatStartDate(r measure.SfnRecord) string {
+	if startDateLayout == time.DateOnly {
+		return r.StartDate
+	}
+	return r.StartTime.Format(startDateLayout)
+}
+
+// parseChangedSince parses --changed-since: an RFC 3339 time, a date in loc,
+// or a duration back from now such as 168h. An empty value yields the zero
+// time, which disables the filter.
+func parseChangedSince(value string, loc *time.Location) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	if t, err := time.ParseInLocation(time.DateOnly, value, loc); err == nil {
+		return t, nil
+	}
+	if d, err := time.ParseDuration(value); err == nil && d > 0 {
+		return now().Add(-d), nil
+	}
+	return time.Time{}, fmt.Errorf("invalid --changed-since %q: want an RFC 3339 time, a date such as 2006-01-02 or a duration such as 168h", value)
+}
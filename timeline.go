@@ -0,0 +1,48 @@
+package main
+
+import (
+	"slices"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/Finatext/measure-sfn/measure"
+)
+
+// createTimelineCsvFile writes the start and stop time of every execution to
+// timeline.csv in start order, for plotting a Gantt chart of the executions
+// of a single state machine. DurationSeconds ignores --unit and keeps full
+// precision so that plotted bars line up with their timestamps.
+func createTimelineCsvFile(records measure.SfnRecords) error {
+	sorted := slices.Clone(records)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].StartTime.Before(sorted[j].StartTime) })
+
+	w, err := createOutput(outputPath("timeline.csv"))
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+	writer, err := newCsvWriter(w)
+	if err != nil {
+		return err
+	}
+
+	if err := writer.Write([]string{"Execution", "Start", "Stop", "DurationSeconds", "Status"}); err != nil {
+		return err
+	}
+
+	for _, record := range sorted {
+		if err := writer.Write([]string{
+			record.ExecutionName,
+			record.StartTime.Format(time.RFC3339Nano),
+			record.StartTime.Add(record.Duration).Format(time.RFC3339Nano),
+			strconv.FormatFloat(record.Duration.Seconds(), 'f', -1, 64),
+			record.Status,
+		}); err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
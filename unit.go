@@ -0,0 +1,147 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type durationUnit struct {
+	size time.Duration
+	// suffix is appended to duration column headers, e.g. DurationMs.
+	suffix string
+	// symbol follows durations in human-facing output, e.g. 1.50s.
+	symbol string
+}
+
+var durationUnits = map[string]durationUnit{
+	"s":   {time.Second, "", "s"},
+	"ms":  {time.Millisecond, "Ms", "ms"},
+	"min": {time.Minute, "Min", "min"},
+	"h":   {time.Hour, "H", "h"},
+}
+
+// outputUnit is the unit selected by --unit. Durations are kept as
+// time.Duration everywhere and only converted when written.
+var outputUnit = durationUnits["s"]
+
+func parseUnit(name string) (durationUnit, error) {
+	u, ok := durationUnits[name]
+	if !ok {
+		return durationUnit{}, fmt.Errorf("unknown unit: %s", name)
+	}
+	return u, nil
+}
+
+// roundingModes are the --rounding choices. Each divides a by b, where b is
+// positive, rounding the quotient to an integer.
+var roundingModes = map[string]func(a, b int64) int64{
+	"nearest": func(a, b int64) int64 {
+		q, r := a/b, a%b
+		if 2*r >= b {
+			q++
+		} else if 2*r <= -b {
+			q--
+		}
+		return q
+	},
+	"ceil": func(a, b int64) int64 {
+		q := a / b
+		if a%b > 0 {
+			q++
+		}
+		return q
+	},
+	"floor": func(a, b int64) int64 {
+		q := a / b
+		if a%b < 0 {
+			q--
+		}
+		return q
+	},
+}
+
+// outputRounding is the mode selected by --rounding.
+var outputRounding = roundingModes["nearest"]
+
+func parseRounding(name string) (func(a, b int64) int64, error) {
+	r, ok := roundingModes[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown rounding: %s", name)
+	}
+	return r, nil
+}
+
+// durationFormat is set by --duration-format: number, the default, writes
+// durations as numbers in the output unit, while iso8601 and human write
+// strings such as PT1M33.5S and 1m33.5s.
+var durationFormat = "number"
+
+// durationFormats are the --duration-format choices.
+var durationFormats = []string{"number", "iso8601", "human"}
+
+// durationIsNumber reports whether the duration columns hold numbers rather
+// than strings, which decides their JSON type.
+func durationIsNumber() bool {
+	return durationFormat == "number"
+}
+
+// formatDuration renders d in the output unit with two decimals, rounded with
+// the output rounding mode. The arithmetic stays in integer nanoseconds so
+// that ceil and floor never flip on floating point error. The string formats
+// of --duration-format are rounded to hundredths of a second the same way.
+func formatDuration(d time.Duration) string {
+	switch durationFormat {
+	case "iso8601":
+		return formatISO8601(time.Duration(outputRounding(int64(d), int64(time.Second)/100)) * time.Second / 100)
+	case "human":
+		return (time.Duration(outputRounding(int64(d), int64(time.Second)/100)) * time.Second / 100).String()
+	}
+
+	hundredths := outputRounding(int64(d), int64(outputUnit.size)/100)
+	sign := ""
+	if hundredths < 0 {
+		sign, hundredths = "-", -hundredths
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, hundredths/100, hundredths%100)
+}
+
+// formatISO8601 renders d as an ISO 8601 duration of hours, minutes and
+// seconds, e.g. PT1H2M3.5S, leaving out zero components. Days are never used
+// as they are not always 24 hours long. A negative d gets a leading minus, a
+// common extension of the standard.
+func formatISO8601(d time.Duration) string {
+	if d == 0 {
+		return "PT0S"
+	}
+
+	var b strings.Builder
+	if d < 0 {
+		b.WriteByte('-')
+		d = -d
+	}
+	b.WriteString("PT")
+	if h := d / time.Hour; h > 0 {
+		b.WriteString(strconv.FormatInt(int64(h), 10) + "H")
+	}
+	if m := d % time.Hour / time.Minute; m > 0 {
+		b.WriteString(strconv.FormatInt(int64(m), 10) + "M")
+	}
+	if s := d % time.Minute; s > 0 {
+		// Whole seconds and the fraction are formatted apart so that no
+		// float rounding creeps in, e.g. 0.1s stays 0.1.
+		b.WriteString(strconv.FormatInt(int64(s/time.Second), 10))
+		if frac := s % time.Second; frac > 0 {
+			b.WriteString(strings.TrimRight(fmt.Sprintf(".%09d", frac), "0"))
+		}
+		b.WriteByte('S')
+	}
+	return b.String()
+}
+
+// durationHeader labels a duration column with the output unit. Seconds keep
+// the bare name for compatibility with existing files.
+func durationHeader(name string) string {
+	return name + outputUnit.suffix
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// checkOutputDirs makes sure the directories output files go to can be
+// written, without creating them: a missing directory is checked through its
+// closest existing parent.
+func checkOutputDirs() error {
+	dirs := []string{*outDir}
+	if *perMachine != "" {
+		dirs = append(dirs, resolveAgainst(*outDir, *perMachine))
+	}
+	if *cacheDir != "" {
+		dirs = append(dirs, *cacheDir)
+	}
+
+	for _, dir := range dirs {
+		if err := checkWritable(dir); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func checkWritable(dir string) error {
+	if dir == "" {
+		dir = "."
+	}
+	for {
+		info, err := os.Stat(dir)
+		if errors.Is(err, os.ErrNotExist) && filepath.Dir(dir) != dir {
+			dir = filepath.Dir(dir)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			return fmt.Errorf("%s is not a directory", dir)
+		}
+		break
+	}
+
+	f, err := os.CreateTemp(dir, ".measure-sfn-validate-*")
+	if err != nil {
+		return fmt.Errorf("%s is not writable: %w", dir, err)
+	}
+	f.Close()
+	return os.Remove(f.Name())
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/Finatext/measure-sfn/measure"
+)
+
+// splitWeekend partitions records by whether they started on a Saturday or
+// Sunday in the configured time zone.
+func splitWeekend(records measure.SfnRecords) (weekday, weekend measure.SfnRecords) {
+	for _, record := range records {
+		switch record.StartTime.Weekday() {
+		case time.Saturday, time.Sunday:
+			weekend = append(weekend, record)
+		default:
+			weekday = append(weekday, record)
+		}
+	}
+	return weekday, weekend
+}
+
+// createWeekdayWeekendCsvFile writes Count/Avg/P95 of weekday and weekend
+// executions per state machine to weekday-weekend.csv. Stats of an empty
+// partition are left blank.
+func createWeekdayWeekendCsvFile(aggregated measure.AggregatedRecordMap) error {
+	w, err := createOutput(outputPath("weekday-weekend.csv"))
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+	writer, err := newCsvWriter(w)
+	if err != nil {
+		return err
+	}
+
+	if err := writer.Write([]string{
+		"Name",
+		"WeekdayCount", durationHeader("WeekdayAvg"), durationHeader("WeekdayP95"),
+		"WeekendCount", durationHeader("WeekendAvg"), durationHeader("WeekendP95"),
+	}); err != nil {
+		return err
+	}
+
+	for _, name := range aggregated.Names() {
+		weekday, weekend := splitWeekend(aggregated[name])
+		row := []string{name}
+		for _, partition := range []measure.SfnRecords{weekday, weekend} {
+			if partition.Len() == 0 {
+				row = append(row, "0", "", "")
+				continue
+			}
+			row = append(row,
+				strconv.Itoa(partition.Len()),
+				formatDuration(partition.AvgDuration()),
+				formatDuration(partition.Percentile(95)),
+			)
+		}
+		if err := writer.Write(row); err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sts"
+)
+
+// printIdentities writes the account, ARN and user ID that each profile
+// resolves to, including --role-arn, with STS GetCallerIdentity. It needs no
+// SFN permissions.
+func printIdentities(ctx context.Context, w io.Writer, c sessionConfig, profiles []string, region string) error {
+	c.Region = region
+	for _, profile := range profiles {
+		c.Profile = profile
+		sess, creds, err := newSession(ctx, c)
+		if err != nil {
+			return withProfile(profile, len(profiles), err)
+		}
+		out, err := sts.New(sess, aws.NewConfig().WithCredentials(creds)).GetCallerIdentityWithContext(ctx, &sts.GetCallerIdentityInput{})
+		if err != nil {
+			return withProfile(profile, len(profiles), err)
+		}
+
+		if len(profiles) > 1 {
+			fmt.Fprintf(w, "[%s]\n", profile)
+		}
+		fmt.Fprintf(w, "Account: %s\nArn:     %s\nUserId:  %s\n",
+			aws.StringValue(out.Account), aws.StringValue(out.Arn), aws.StringValue(out.UserId))
+	}
+	return nil
+}
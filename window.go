@@ -0,0 +1,36 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/Finatext/measure-sfn/measure"
+)
+
+// checkWindow is the --assert-window self-check of the lookback filter: every
+// record must have started, or with --filter-by stop stopped, between since
+// and until. A violation means the filtering is wrong, so the run fails
+// before writing anything rather than reporting executions outside the
+// window.
+func checkWindow(records measure.SfnRecords, since, until time.Time) error {
+	var outside int
+	var first measure.SfnRecord
+	for _, record := range records {
+		filtered := record.StartTime
+		if *filterBy == "stop" {
+			filtered = record.StartTime.Add(record.Duration)
+		}
+		if filtered.Before(since) || filtered.After(until) {
+			if outside == 0 {
+				first = record
+			}
+			outside++
+		}
+	}
+	if outside == 0 {
+		return nil
+	}
+	return fmt.Errorf("--assert-window: %d of %d records are outside the window %s to %s, e.g. %s started at %s",
+		outside, len(records), since.Format(time.RFC3339), until.Format(time.RFC3339),
+		first.ExecutionArn, first.StartTime.Format(time.RFC3339))
+}